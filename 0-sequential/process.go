@@ -2,8 +2,11 @@ package sequential
 
 import (
 	"context"
+	"errors"
 	"io"
 	"time"
+
+	"example.com/pipelines-and-cancellation/fault"
 )
 
 type Meeting struct {
@@ -41,45 +44,133 @@ type CreateMeetingDatumArguments struct {
 
 //go:generate go run github.com/matryer/moq/... -out autogen_store.go . StoreInterface
 type StoreInterface interface {
+	// CreateMeetingDatum must not close args.Content; the processor owns
+	// it and closes it once the call returns
 	CreateMeetingDatum(ctx context.Context, args CreateMeetingDatumArguments) error
 }
 
+// CheckpointStore persists the watermark between runs.
+type CheckpointStore interface {
+	// Load returns the zero time if no checkpoint has been saved
+	Load(ctx context.Context) (time.Time, error)
+	Save(ctx context.Context, t time.Time) error
+}
+
+type Config struct {
+	// ContinueOnError keeps processing the remaining meetings when one
+	// fails. The watermark stops at the last meeting before the first
+	// failure and Process returns a *RunReport listing every failure.
+	//
+	// Errors are classified with package fault. Meetings that are not
+	// found are skipped without holding the watermark back, whether or not
+	// ContinueOnError is set. A permanent failure stops the run even in
+	// continue-on-error mode, since no later run could get past it.
+	ContinueOnError bool
+}
+
 type Processor struct {
 	Client ClientInterface
 	Store  StoreInterface
+	Cfg    Config
+
+	// Optional
+	Checkpoint CheckpointStore
 }
 
 func (p *Processor) Process(ctx context.Context) (t time.Time, _ error) {
-	meetings, err := p.Client.ListMeetings(ctx, nil)
+	from, err := p.loadCheckpoint(ctx)
+	if err != nil {
+		return t, err
+	}
+
+	// Resume from the last run, if any. The meeting at the watermark
+	// itself may be listed and uploaded again.
+	params := &ListMeetingsParams{}
+	if !from.IsZero() {
+		t = from
+		params.From = &from
+	}
+
+	meetings, err := p.Client.ListMeetings(ctx, params)
 	if err != nil {
 		return t, err
 	}
 
+	var report RunReport
 	for _, meeting := range meetings {
-		args, err := p.TransformToDatum(ctx, meeting)
-		if err != nil {
+		err := p.processMeeting(ctx, meeting)
+		var mf *MeetingFailure
+		switch {
+		case err == nil:
+			report.Uploaded++
+		case ctx.Err() != nil || !errors.As(err, &mf):
 			return t, err
+		case fault.IsNotFound(err):
+			// Nothing left to upload, so the watermark may pass it
+			report.Skipped = append(report.Skipped, *mf)
+		case !p.Cfg.ContinueOnError || fault.IsPermanent(err):
+			return t, err
+		default:
+			report.Failures = append(report.Failures, *mf)
+			continue
 		}
 
-		if err := p.Store.CreateMeetingDatum(ctx, args); err != nil {
-			return t, err
+		// The watermark must not pass a failed meeting
+		if len(report.Failures) > 0 {
+			continue
 		}
 
 		t = meeting.Start
+
+		if err := p.saveCheckpoint(ctx, t); err != nil {
+			return t, err
+		}
+	}
+
+	return t, report.Err()
+}
+
+func (p *Processor) processMeeting(ctx context.Context, m Meeting) error {
+	args, err := p.TransformToDatum(ctx, m)
+	if err != nil {
+		return err
 	}
 
-	return t, nil
+	err = p.Store.CreateMeetingDatum(ctx, args)
+	args.Content.Close()
+	if err != nil {
+		return failure(m, StageUpload, err)
+	}
+
+	return nil
+}
+
+func (p *Processor) loadCheckpoint(ctx context.Context) (time.Time, error) {
+	if p.Checkpoint == nil {
+		return time.Time{}, nil
+	}
+	return p.Checkpoint.Load(ctx)
+}
+
+func (p *Processor) saveCheckpoint(ctx context.Context, t time.Time) error {
+	if p.Checkpoint == nil {
+		return nil
+	}
+	return p.Checkpoint.Save(ctx, t)
 }
 
+// TransformToDatum downloads and enriches m. On success the caller owns the
+// returned Content and must close it.
 func (p *Processor) TransformToDatum(ctx context.Context, m Meeting) (args CreateMeetingDatumArguments, _ error) {
 	rc, err := p.Client.DownloadMeeting(ctx, m.DownloadURL)
 	if err != nil {
-		return args, err
+		return args, failure(m, StageDownload, err)
 	}
 
 	participants, err := p.Client.GetMeetingParticipants(ctx, m.ID)
 	if err != nil {
-		return args, err
+		rc.Close()
+		return args, failure(m, StageParticipants, err)
 	}
 
 	return CreateMeetingDatumArguments{
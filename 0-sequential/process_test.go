@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
-	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	sequential "example.com/pipelines-and-cancellation/0-sequential"
+	"example.com/pipelines-and-cancellation/checkpoint"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/internal/fixture"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestProcess(t *testing.T) {
+	// Every downloaded recording must be closed exactly once
+	var opens, closes atomic.Int64
+
 	// 200 milliseconds
 	const maxNetworkLatency = 200
 
@@ -53,7 +61,7 @@ func TestProcess(t *testing.T) {
 			DownloadMeetingFunc: func(_ context.Context, url string) (io.ReadCloser, error) {
 				// Simulate I/O
 				time.Sleep(time.Duration(rand.Intn(maxNetworkLatency)) * time.Millisecond)
-				return os.Open(url)
+				return fixture.OpenCounted(url, &opens, &closes)
 			},
 			GetMeetingParticipantsFunc: func(_ context.Context, meetingID string) ([]sequential.Participant, error) {
 				// Simulate I/O
@@ -83,7 +91,6 @@ func TestProcess(t *testing.T) {
 				// Simulate I/O
 				time.Sleep(time.Duration(rand.Intn(maxNetworkLatency)) * time.Millisecond)
 				io.Copy(io.Discard, args.Content)
-				args.Content.Close()
 				return nil
 			},
 		},
@@ -95,4 +102,140 @@ func TestProcess(t *testing.T) {
 	assert.Equal(t, time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC), got)
 	calls := p.Store.(*sequential.StoreInterfaceMock).CreateMeetingDatumCalls()
 	assert.Len(t, calls, 2)
+	assert.Equal(t, opens.Load(), closes.Load())
+}
+
+func TestProcessCheckpoint(t *testing.T) {
+	cp := &checkpoint.FileStore{Path: filepath.Join(t.TempDir(), "watermark")}
+	from := time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, cp.Save(context.Background(), from))
+
+	client, store := newMocks(0)
+	client.ListMeetingsFunc = func(_ context.Context, params *sequential.ListMeetingsParams) ([]sequential.Meeting, error) {
+		return generateMeetings(2, 4), nil
+	}
+	p := sequential.Processor{
+		Client:     client,
+		Store:      store,
+		Checkpoint: cp,
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	want := time.Date(2023, time.January, 4, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, want, got)
+	calls := client.ListMeetingsCalls()
+	if assert.Len(t, calls, 1) && assert.NotNil(t, calls[0].Params.From) {
+		assert.Equal(t, from, *calls[0].Params.From)
+	}
+	saved, _ := cp.Load(context.Background())
+	assert.True(t, want.Equal(saved))
+}
+
+func TestProcessContinueOnError(t *testing.T) {
+	client, store := newMocks(3)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]sequential.Participant, error) {
+		if meetingID == "2" {
+			return nil, fmt.Errorf("forced participants error for %s", meetingID)
+		}
+		return nil, nil
+	}
+	p := sequential.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: sequential.Config{
+			ContinueOnError: true,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	var report *sequential.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 2, report.Uploaded)
+		if assert.Len(t, report.Failures, 1) {
+			assert.Equal(t, "2", report.Failures[0].MeetingID)
+			assert.Equal(t, sequential.StageParticipants, report.Failures[0].Stage)
+			assert.Equal(t, 1, report.Failures[0].Attempts)
+		}
+	}
+	assert.Equal(t, time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), got)
+	assert.Len(t, store.CreateMeetingDatumCalls(), 2)
+}
+
+func TestProcessFault(t *testing.T) {
+	client, store := newMocks(5)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]sequential.Participant, error) {
+		switch meetingID {
+		case "2":
+			return nil, fault.NotFound(fmt.Errorf("meeting %s was deleted", meetingID))
+		case "4":
+			return nil, fault.Permanent(fmt.Errorf("forced participants error for %s", meetingID))
+		}
+		return nil, nil
+	}
+	p := sequential.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: sequential.Config{
+			ContinueOnError: true,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	// A permanent failure stops the run even in continue-on-error mode
+	var mf *sequential.MeetingFailure
+	if assert.ErrorAs(t, gerr, &mf) {
+		assert.Equal(t, "4", mf.MeetingID)
+	}
+	assert.True(t, fault.IsPermanent(gerr))
+	// Skipped meetings don't hold the watermark back
+	assert.Equal(t, time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC), got)
+	assert.Len(t, store.CreateMeetingDatumCalls(), 2)
+}
+
+// newMocks returns a client that lists meetings 1 to n in one call, with
+// content and without participants, and a store that accepts everything.
+// Tests override the calls they are about.
+func newMocks(n int) (*sequential.ClientInterfaceMock, *sequential.StoreInterfaceMock) {
+	client := &sequential.ClientInterfaceMock{
+		ListMeetingsFunc: func(_ context.Context, params *sequential.ListMeetingsParams) ([]sequential.Meeting, error) {
+			return generateMeetings(0, n), nil
+		},
+		DownloadMeetingFunc: func(_ context.Context, url string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("content")), nil
+		},
+		GetMeetingParticipantsFunc: func(_ context.Context, meetingID string) ([]sequential.Participant, error) {
+			return nil, nil
+		},
+	}
+	store := &sequential.StoreInterfaceMock{
+		CreateMeetingDatumFunc: func(_ context.Context, args sequential.CreateMeetingDatumArguments) error {
+			return nil
+		},
+	}
+	return client, store
+}
+
+// generateMeetings returns meetings begin+1 to end, one a day from
+// 2023-01-01 on.
+func generateMeetings(begin, end int) []sequential.Meeting {
+	meetings := make([]sequential.Meeting, 0, end-begin)
+
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, begin)
+
+	for i := begin + 1; i <= end; i++ {
+		id := strconv.Itoa(i)
+		meetings = append(meetings, sequential.Meeting{
+			ID:          id,
+			Topic:       fmt.Sprintf("Meeting %s", id),
+			Start:       start,
+			DownloadURL: filepath.Join("../testdata", "test.mp4"),
+		})
+		start = start.AddDate(0, 0, 1)
+	}
+
+	return meetings
 }
@@ -2,9 +2,13 @@ package concurrent
 
 import (
 	"context"
+	"errors"
 	"io"
+	"sort"
 	"time"
 
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/watermark"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -43,48 +47,95 @@ type CreateMeetingDatumArguments struct {
 
 //go:generate go run github.com/matryer/moq/... -out autogen_store.go . StoreInterface
 type StoreInterface interface {
+	// CreateMeetingDatum must not close args.Content; the processor owns
+	// it and closes it once the call returns
 	CreateMeetingDatum(ctx context.Context, args CreateMeetingDatumArguments) error
 }
 
 type Config struct {
 	MeetingConcurrency int
+
+	// ContinueOnError keeps processing the remaining meetings when one
+	// fails. The watermark stops at the last meeting before the first
+	// failure and Process returns a *RunReport listing every failure.
+	//
+	// Errors are classified with package fault. Meetings that are not
+	// found are skipped without holding the watermark back, whether or not
+	// ContinueOnError is set. A permanent failure stops the run even in
+	// continue-on-error mode, since no later run could get past it.
+	ContinueOnError bool
+}
+
+// CheckpointStore persists the watermark between runs.
+type CheckpointStore interface {
+	// Load returns the zero time if no checkpoint has been saved
+	Load(ctx context.Context) (time.Time, error)
+	Save(ctx context.Context, t time.Time) error
 }
 
 type Processor struct {
 	Client ClientInterface
 	Store  StoreInterface
 	Cfg    Config
+
+	// Optional
+	Checkpoint CheckpointStore
 }
 
 func (p *Processor) Process(ctx context.Context) (time.Time, error) {
-	// Source
-	meetings, err := p.Client.ListMeetings(ctx, nil)
+	from, err := p.loadCheckpoint(ctx)
 	if err != nil {
 		return time.Time{}, err
 	}
 
+	// Resume from the last run, if any. The meeting at the watermark
+	// itself may be listed and uploaded again.
+	params := &ListMeetingsParams{}
+	if !from.IsZero() {
+		params.From = &from
+	}
+
+	// Source
+	meetings, err := p.Client.ListMeetings(ctx, params)
+	if err != nil {
+		return from, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	g, ctx := errgroup.WithContext(ctx)
 	g.SetLimit(p.Cfg.MeetingConcurrency)
 
-	tms := make(chan time.Time)
+	// Meetings finish in any order; the sequence number lets the sink
+	// work out which prefix of the listing is safely uploaded
+	type completion struct {
+		seq     int
+		start   time.Time
+		fail    *MeetingFailure
+		skipped bool
+	}
+	done := make(chan completion)
 
 	// Stage 1
 	go func() {
-		defer close(tms)
-		for _, meeting := range meetings {
-			meeting := meeting
+		defer close(done)
+		for i, meeting := range meetings {
+			i, meeting := i, meeting
 			g.Go(func() error {
-				args, err := p.TransformToDatum(ctx, meeting)
-				if err != nil {
-					return err
-				}
-
-				if err := p.Store.CreateMeetingDatum(ctx, args); err != nil {
-					return err
+				c := completion{seq: i, start: meeting.Start}
+				if err := p.processMeeting(ctx, meeting); err != nil {
+					if ctx.Err() != nil || !errors.As(err, &c.fail) {
+						return err
+					}
+					c.skipped = fault.IsNotFound(err)
+					if !c.skipped && (!p.Cfg.ContinueOnError || fault.IsPermanent(err)) {
+						return err
+					}
 				}
 
 				select {
-				case tms <- meeting.Start:
+				case done <- c:
 					return nil
 				case <-ctx.Done():
 					return ctx.Err()
@@ -95,16 +146,84 @@ func (p *Processor) Process(ctx context.Context) (time.Time, error) {
 	}()
 
 	// Sink
-	var t time.Time
-	for tm := range tms {
-		if tm.After(t) {
-			t = tm
+	wm := watermark.New(from)
+	var report RunReport
+	var failed, skipped []completion
+	var serr error
+	for c := range done {
+		switch {
+		case c.skipped:
+			// Nothing left to upload, so the watermark may pass it
+			skipped = append(skipped, c)
+		case c.fail != nil:
+			wm.Fail(c.seq)
+			failed = append(failed, c)
+			continue
+		default:
+			report.Uploaded++
 		}
+
+		t, advanced := wm.Done(c.seq, c.start)
+		if advanced && serr == nil {
+			if serr = p.saveCheckpoint(ctx, t); serr != nil {
+				// Stop the pipeline but keep draining done
+				cancel()
+			}
+		}
+	}
+
+	if err := g.Wait(); serr == nil {
+		serr = err
+	}
+
+	// Reported in listing order, not in the order they finished in
+	sort.Slice(failed, func(i, j int) bool { return failed[i].seq < failed[j].seq })
+	for _, c := range failed {
+		report.Failures = append(report.Failures, *c.fail)
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].seq < skipped[j].seq })
+	for _, c := range skipped {
+		report.Skipped = append(report.Skipped, *c.fail)
+	}
+
+	if serr == nil {
+		serr = report.Err()
+	}
+
+	return wm.Watermark(), serr
+}
+
+func (p *Processor) processMeeting(ctx context.Context, m Meeting) error {
+	args, err := p.TransformToDatum(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	err = p.Store.CreateMeetingDatum(ctx, args)
+	args.Content.Close()
+	if err != nil {
+		return failure(m, StageUpload, err)
 	}
 
-	return t, g.Wait()
+	return nil
 }
 
+func (p *Processor) loadCheckpoint(ctx context.Context) (time.Time, error) {
+	if p.Checkpoint == nil {
+		return time.Time{}, nil
+	}
+	return p.Checkpoint.Load(ctx)
+}
+
+func (p *Processor) saveCheckpoint(ctx context.Context, t time.Time) error {
+	if p.Checkpoint == nil {
+		return nil
+	}
+	return p.Checkpoint.Save(ctx, t)
+}
+
+// TransformToDatum downloads and enriches m. On success the caller owns the
+// returned Content and must close it.
 func (p *Processor) TransformToDatum(ctx context.Context, m Meeting) (CreateMeetingDatumArguments, error) {
 	args := CreateMeetingDatumArguments{
 		Topic: m.Topic,
@@ -115,14 +234,28 @@ func (p *Processor) TransformToDatum(ctx context.Context, m Meeting) (CreateMeet
 	g.Go(func() error {
 		var err error
 		args.Content, err = p.Client.DownloadMeeting(ctx, m.DownloadURL)
-		return err
+		if err != nil {
+			return failure(m, StageDownload, err)
+		}
+		return nil
 	})
 
 	g.Go(func() error {
 		var err error
 		args.Participants, err = p.Client.GetMeetingParticipants(ctx, m.ID)
-		return err
+		if err != nil {
+			return failure(m, StageParticipants, err)
+		}
+		return nil
 	})
 
-	return args, g.Wait()
+	if err := g.Wait(); err != nil {
+		// The download may have succeeded even though its sibling failed
+		if args.Content != nil {
+			args.Content.Close()
+		}
+		return CreateMeetingDatumArguments{}, err
+	}
+
+	return args, nil
 }
@@ -5,27 +5,33 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	concurrent "example.com/pipelines-and-cancellation/1-concurrent"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/internal/fixture"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestProcess(t *testing.T) {
+	// Every downloaded recording must be closed exactly once
+	var opens, closes atomic.Int64
+
 	const (
 		// 200 milliseconds
 		maxNetworkLatency = 200
 
 		maxNumberOfMeetings = 250
 
-		// Forcing an error will (eventually) cause the test to fail
-		// because we have a race condition in our solution
+		// Meetings after this one may finish first, but must not
+		// move the watermark past it
 		problematicMeetingID = "113"
 	)
 
@@ -43,7 +49,7 @@ func TestProcess(t *testing.T) {
 				case <-ctx.Done():
 					return nil, ctx.Err()
 				default:
-					return os.Open(url)
+					return fixture.OpenCounted(url, &opens, &closes)
 				}
 			},
 			GetMeetingParticipantsFunc: func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
@@ -84,7 +90,6 @@ func TestProcess(t *testing.T) {
 				// Simulate I/O
 				time.Sleep(time.Duration(rand.Intn(maxNetworkLatency)) * time.Millisecond)
 				io.Copy(io.Discard, args.Content)
-				args.Content.Close()
 				return ctx.Err()
 			},
 		},
@@ -100,6 +105,128 @@ func TestProcess(t *testing.T) {
 	days-- // IDs start at 1
 	assert.Less(t, got, time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days))
 	assert.Equal(t, 2, runtime.NumGoroutine())
+	assert.Equal(t, opens.Load(), closes.Load())
+}
+
+func TestProcessContinueOnError(t *testing.T) {
+	const problematicMeetingID = "13"
+
+	client, store := newMocks(50)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		if meetingID == problematicMeetingID {
+			return nil, fmt.Errorf("forced participants error for %s", meetingID)
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			MeetingConcurrency: 5,
+			ContinueOnError:    true,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 49, report.Uploaded)
+		if assert.Len(t, report.Failures, 1) {
+			assert.Equal(t, problematicMeetingID, report.Failures[0].MeetingID)
+			assert.Equal(t, concurrent.StageParticipants, report.Failures[0].Stage)
+		}
+	}
+	// Last meeting before the failure
+	assert.Equal(t, time.Date(2023, time.January, 12, 0, 0, 0, 0, time.UTC), got)
+}
+
+func TestProcessFailureOrder(t *testing.T) {
+	client, store := newMocks(10)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		switch meetingID {
+		case "2":
+			// Listed first, fails last
+			time.Sleep(30 * time.Millisecond)
+			return nil, fmt.Errorf("forced participants error for %s", meetingID)
+		case "4":
+			return nil, fmt.Errorf("forced participants error for %s", meetingID)
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			MeetingConcurrency: 5,
+			ContinueOnError:    true,
+		},
+	}
+
+	_, gerr := p.Process(context.Background())
+
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) && assert.Len(t, report.Failures, 2) {
+		assert.Equal(t, "2", report.Failures[0].MeetingID)
+		assert.Equal(t, "4", report.Failures[1].MeetingID)
+	}
+	assert.ErrorContains(t, gerr, "first: meeting 2:")
+}
+
+func TestProcessFault(t *testing.T) {
+	client, store := newMocks(5)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		switch meetingID {
+		case "2":
+			return nil, fault.NotFound(fmt.Errorf("meeting %s was deleted", meetingID))
+		case "4":
+			return nil, fault.Permanent(fmt.Errorf("forced participants error for %s", meetingID))
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			MeetingConcurrency: 1,
+			ContinueOnError:    true,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	// A permanent failure stops the run even in continue-on-error mode
+	var mf *concurrent.MeetingFailure
+	if assert.ErrorAs(t, gerr, &mf) {
+		assert.Equal(t, "4", mf.MeetingID)
+	}
+	assert.True(t, fault.IsPermanent(gerr))
+	// Skipped meetings don't hold the watermark back
+	assert.Equal(t, time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC), got)
+}
+
+// newMocks returns a client that lists meetings 1 to n in one call and
+// downloads them with some jitter, without participants, and a store that
+// accepts everything. Tests override the calls they are about.
+func newMocks(n int) (*concurrent.ClientInterfaceMock, *concurrent.StoreInterfaceMock) {
+	client := &concurrent.ClientInterfaceMock{
+		ListMeetingsFunc: func(_ context.Context, params *concurrent.ListMeetingsParams) ([]concurrent.Meeting, error) {
+			return generateMeetings(0, n), nil
+		},
+		DownloadMeetingFunc: func(_ context.Context, url string) (io.ReadCloser, error) {
+			time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+			return io.NopCloser(strings.NewReader("content")), nil
+		},
+		GetMeetingParticipantsFunc: func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+			return nil, nil
+		},
+	}
+	store := &concurrent.StoreInterfaceMock{
+		CreateMeetingDatumFunc: func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			return nil
+		},
+	}
+	return client, store
 }
 
 func generateMeetings(begin, end int) []concurrent.Meeting {
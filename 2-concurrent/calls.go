@@ -0,0 +1,264 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"example.com/pipelines-and-cancellation/breaker"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/hedge"
+	"example.com/pipelines-and-cancellation/ratelimit"
+	"example.com/pipelines-and-cancellation/retry"
+	"example.com/pipelines-and-cancellation/trace"
+)
+
+// Call identifies a ClientInterface or StoreInterface method.
+type Call string
+
+const (
+	CallListMeetings           Call = "list"
+	CallDownloadMeeting        Call = "download"
+	CallGetMeetingParticipants Call = "participants"
+	CallCreateMeetingDatum     Call = "store"
+)
+
+// TimeoutError reports an attempt at a call that ran out of its budget in
+// Config.Timeouts. It is classified as fault.ErrTimeout, so it is retried by
+// default, and never matches context.Canceled or context.DeadlineExceeded,
+// which are left to the run being cancelled.
+type TimeoutError struct {
+	Call    Call
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s call timed out after %s", e.Call, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == fault.ErrTimeout
+}
+
+// method returns the name of the interface method c calls.
+func (c Call) method() string {
+	switch c {
+	case CallListMeetings:
+		return "ClientInterface.ListPaginatedMeetings"
+	case CallDownloadMeeting:
+		return "ClientInterface.DownloadMeeting"
+	case CallGetMeetingParticipants:
+		return "ClientInterface.GetMeetingParticipants"
+	case CallCreateMeetingDatum:
+		return "StoreInterface.CreateMeetingDatum"
+	}
+	return string(c)
+}
+
+func (p *Processor) retryPolicy(c Call) retry.Policy {
+	if rp, ok := p.Cfg.RetryOverrides[c]; ok {
+		return rp
+	}
+	return p.Cfg.Retry
+}
+
+// newLimiters returns a rate limiter for every call, shared by every
+// goroutine in a run. Calls without a limit in Config.RateLimits still get
+// one, so that they can be paused.
+func (p *Processor) newLimiters() map[Call]*ratelimit.Limiter {
+	calls := []Call{CallListMeetings, CallDownloadMeeting, CallGetMeetingParticipants, CallCreateMeetingDatum}
+	limiters := make(map[Call]*ratelimit.Limiter, len(calls))
+	for _, c := range calls {
+		limiters[c] = ratelimit.New(p.Cfg.RateLimits[c])
+	}
+	return limiters
+}
+
+// BreakerPolicy decides what uploads do while Config.StoreBreaker is open.
+type BreakerPolicy int
+
+const (
+	// BreakerAbort fails the run with an error wrapping breaker.ErrOpen.
+	// The meeting that ran into it isn't dead-lettered, even in
+	// continue-on-error mode.
+	BreakerAbort BreakerPolicy = iota
+
+	// BreakerPause holds every upload back until the breaker lets a
+	// trial call through, and for as long as trial calls fail. Waiting
+	// doesn't use up retry attempts, however long the store is down. A
+	// trial call whose content can't be rewound isn't made again: the
+	// upload fails with its error.
+	BreakerPause
+)
+
+// guarded calls fn through c's circuit breaker, if any. In pause mode, fn
+// is only called again after a failed trial call if it is repeatable.
+func (p *Processor) guarded(ctx context.Context, c Call, repeatable bool, fn func() error) error {
+	var b *breaker.Breaker
+	if c == CallCreateMeetingDatum {
+		b = p.Cfg.StoreBreaker
+	}
+	if b == nil {
+		return fn()
+	}
+
+	for {
+		if p.Cfg.BreakerPolicy == BreakerPause {
+			if err := b.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := b.Do(fn)
+		if p.Cfg.BreakerPolicy != BreakerPause || err == nil || ctx.Err() != nil {
+			return err
+		}
+		if errors.Is(err, breaker.ErrOpen) {
+			// Another upload got the trial call, fn wasn't called
+			continue
+		}
+		if repeatable && !fault.IsPermanent(err) && b.State() == breaker.Open {
+			// This trial call failed and the store is still down, which
+			// is no reason to use up a retry
+			continue
+		}
+		return err
+	}
+}
+
+// limited waits for c's rate limit before calling fn. If the server says
+// fn was rate limited and when to try again, every caller of c is held
+// back until then, not just the one that will retry.
+func (p *Processor) limited(ctx context.Context, c Call, fn func() error) error {
+	limiter := runStateFrom(ctx).limiters[c]
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	done := p.startCall(limiter, c)
+	err := fn()
+	done(err)
+	return err
+}
+
+// admitHedge lets a hedge of c through only if c's rate limit has a token
+// to spare right away, and counts it as a call of its own.
+func (p *Processor) admitHedge(ctx context.Context, c Call) hedge.Admit {
+	limiter := runStateFrom(ctx).limiters[c]
+	return func() (func(error), bool) {
+		if !limiter.Allow() {
+			return nil, false
+		}
+		return p.startCall(limiter, c), true
+	}
+}
+
+// startCall records that a call to c that got its token from limiter has
+// started. Call the returned function with its outcome.
+func (p *Processor) startCall(limiter *ratelimit.Limiter, c Call) func(err error) {
+	done := p.Metrics.startCall(c)
+	return func(err error) {
+		done(err)
+		if after, ok := fault.RetryAfter(err); ok {
+			limiter.Pause(after)
+		}
+	}
+}
+
+// do makes call c for m, if any, under policy, c's circuit breaker, rate
+// limit and timeout, in a span named after the method it calls. fn is only
+// called more than once if it is repeatable. It returns the number of
+// attempts made.
+func (p *Processor) do(ctx context.Context, c Call, policy retry.Policy, m *Meeting, repeatable bool, fn func(ctx context.Context) error) (int, error) {
+	if !repeatable {
+		policy.MaxAttempts = 1
+	}
+
+	var attrs []trace.Attribute
+	if m != nil {
+		attrs = meetingAttributes(*m)
+	}
+
+	ctx, span := p.tracer().Start(ctx, c.method(), attrs...)
+	policy.OnRetry = p.logRetry(ctx, c, m, policy.OnRetry)
+	attempts, err := policy.Do(ctx, func(ctx context.Context) error {
+		return p.guarded(ctx, c, repeatable, func() error {
+			return p.limited(ctx, c, func() error {
+				return p.timed(ctx, c, fn)
+			})
+		})
+	})
+	span.SetAttributes(trace.Int("attempts", attempts))
+	endSpan(ctx, span, err)
+	return attempts, err
+}
+
+// timed calls fn with a context that is cancelled with a *TimeoutError once
+// c's budget in Config.Timeouts runs out. An error caused by the budget
+// running out is returned as the *TimeoutError, but one caused by ctx is
+// left alone.
+func (p *Processor) timed(ctx context.Context, c Call, fn func(ctx context.Context) error) error {
+	budget := p.Cfg.Timeouts[c]
+	if budget <= 0 {
+		return fn(ctx)
+	}
+
+	timeout := &TimeoutError{Call: c, Timeout: budget}
+	callCtx, cancel := context.WithTimeoutCause(ctx, budget, timeout)
+	defer cancel()
+
+	err := fn(callCtx)
+	if err != nil && ctx.Err() == nil && context.Cause(callCtx) == timeout {
+		return timeout
+	}
+	return err
+}
+
+func (p *Processor) listMeetings(ctx context.Context, params *ListPaginatedMeetingsParams) (resp ListPaginatedMeetingsResponse, _ error) {
+	_, err := p.do(ctx, CallListMeetings, p.retryPolicy(CallListMeetings), nil, true, func(ctx context.Context) error {
+		var err error
+		resp, err = p.Client.ListPaginatedMeetings(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Processor) downloadMeeting(ctx context.Context, m Meeting) (rc io.ReadCloser, attempts int, _ error) {
+	attempts, err := p.do(ctx, CallDownloadMeeting, p.retryPolicy(CallDownloadMeeting), &m, true, func(ctx context.Context) error {
+		var err error
+		rc, err = p.Client.DownloadMeeting(ctx, m.DownloadURL)
+		return err
+	})
+	return rc, attempts, err
+}
+
+func (p *Processor) getMeetingParticipants(ctx context.Context, m Meeting) (participants []Participant, attempts int, _ error) {
+	attempts, err := p.do(ctx, CallGetMeetingParticipants, p.retryPolicy(CallGetMeetingParticipants), &m, true, func(ctx context.Context) error {
+		var err error
+		participants, err = hedge.Do(ctx, p.Cfg.ParticipantsHedge, p.admitHedge(ctx, CallGetMeetingParticipants), func(ctx context.Context) ([]Participant, error) {
+			return p.Client.GetMeetingParticipants(ctx, m.ID)
+		})
+		return err
+	})
+	return participants, attempts, err
+}
+
+// createMeetingDatum rewinds Content between attempts, so uploads are only
+// retried, or tried again once the breaker lets them, if Content is an
+// io.Seeker.
+func (p *Processor) createMeetingDatum(ctx context.Context, m Meeting, args CreateMeetingDatumArguments) (int, error) {
+	seeker, ok := args.Content.(io.Seeker)
+
+	var attempt int
+	return p.do(ctx, CallCreateMeetingDatum, p.retryPolicy(CallCreateMeetingDatum), &m, ok, func(ctx context.Context) error {
+		attempt++
+		if attempt > 1 {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		return p.Store.CreateMeetingDatum(ctx, args)
+	})
+}
@@ -0,0 +1,231 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeadLetter records a meeting that failed permanently and that the
+// watermark moved past. Other failures hold the watermark back instead, so
+// the next run picks them up again.
+type DeadLetter struct {
+	Meeting Meeting
+	Stage   Stage
+	// Errors is the error chain, outermost first
+	Errors   []string
+	FailedAt time.Time
+}
+
+// DeadLetterStore keeps failed meetings so they can be replayed later.
+// Implementations must be safe for concurrent use.
+type DeadLetterStore interface {
+	Add(ctx context.Context, l DeadLetter) error
+	List(ctx context.Context) ([]DeadLetter, error)
+	// Remove deletes every dead letter for the given meetings
+	Remove(ctx context.Context, meetingIDs []string) error
+}
+
+func (p *Processor) deadLetter(ctx context.Context, m Meeting, err error) error {
+	if p.DeadLetters == nil {
+		return nil
+	}
+
+	l := DeadLetter{
+		Meeting:  m,
+		FailedAt: time.Now(),
+	}
+
+	var mf *MeetingFailure
+	if errors.As(err, &mf) {
+		l.Stage = mf.Stage
+		err = mf.Err
+	}
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		l.Errors = append(l.Errors, err.Error())
+	}
+
+	return p.DeadLetters.Add(ctx, l)
+}
+
+// Replay re-feeds dead-lettered meetings through the transform and upload
+// stages without relisting. Meetings that are uploaded, or no longer exist
+// upstream, are removed from dlq; the others stay there. The checkpoint is
+// left untouched.
+func (p *Processor) Replay(ctx context.Context, dlq DeadLetterStore) (err error) {
+	ctx = withRunState(ctx, &runState{limiters: p.newLimiters()})
+	ctx, span := p.tracer().Start(ctx, "Processor.Replay")
+	defer func() {
+		endSpan(ctx, span, err)
+	}()
+
+	letters, err := dlq.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A meeting may have been dead-lettered by several runs
+	seen := make(map[string]bool, len(letters))
+	meetings := make([]Meeting, 0, len(letters))
+	for _, l := range letters {
+		if !seen[l.Meeting.ID] {
+			seen[l.Meeting.ID] = true
+			meetings = append(meetings, l.Meeting)
+		}
+	}
+
+	// Meetings that fail again are already in dlq
+	rp := *p
+	rp.Checkpoint = nil
+	rp.DeadLetters = nil
+
+	var replayed []string
+	report, err := rp.run(ctx, nil,
+		func(ctx context.Context, out chan<- datum) error {
+			for i, m := range meetings {
+				d := datum{seq: i, meeting: m, deadLettered: true}
+				rp.startSpan(ctx, &d)
+				select {
+				case out <- d:
+				case <-ctx.Done():
+					d.endSpan(ctx, nil)
+					return ctx.Err()
+				}
+			}
+			return nil
+		},
+		func(_ context.Context, d datum) error {
+			if d.fail == nil || d.skipped {
+				replayed = append(replayed, d.meeting.ID)
+			}
+			return nil
+		},
+	)
+
+	if len(replayed) > 0 {
+		// Don't let cancellation stop us from recording progress
+		if rerr := dlq.Remove(context.Background(), replayed); err == nil {
+			err = rerr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return report.Err()
+}
+
+// FileDeadLetterStore keeps dead letters in a JSON-lines file.
+type FileDeadLetterStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileDeadLetterStore) Add(_ context.Context, l DeadLetter) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *FileDeadLetterStore) List(_ context.Context) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+func (s *FileDeadLetterStore) Remove(_ context.Context, meetingIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[string]bool, len(meetingIDs))
+	for _, id := range meetingIDs {
+		remove[id] = true
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// No-op once the rename has succeeded
+	defer os.Remove(f.Name())
+
+	enc := json.NewEncoder(f)
+	for _, l := range letters {
+		if remove[l.Meeting.ID] {
+			continue
+		}
+		if err := enc.Encode(l); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.Path)
+}
+
+func (s *FileDeadLetterStore) read() ([]DeadLetter, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// A line is as long as the error chain it records, so don't read
+	// line by line
+	var letters []DeadLetter
+	dec := json.NewDecoder(f)
+	for {
+		var l DeadLetter
+		err := dec.Decode(&l)
+		if err == io.EOF {
+			return letters, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, l)
+	}
+}
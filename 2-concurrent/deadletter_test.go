@@ -0,0 +1,97 @@
+package concurrent_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"example.com/pipelines-and-cancellation/fault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	const problematicMeetingID = "13"
+
+	var healed atomic.Bool
+	client, store := newMocks(20)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		if meetingID == problematicMeetingID && !healed.Load() {
+			return nil, fault.Permanent(fmt.Errorf("forced participants error for %s: %w", meetingID, io.ErrUnexpectedEOF))
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+			ContinueOnError:        true,
+		},
+		DeadLetters: &concurrent.FileDeadLetterStore{
+			Path: filepath.Join(t.TempDir(), "dead-letters.jsonl"),
+		},
+	}
+	ctx := context.Background()
+
+	_, gerr := p.Process(ctx)
+	assert.ErrorContains(t, gerr, problematicMeetingID)
+
+	letters, err := p.DeadLetters.List(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, problematicMeetingID, letters[0].Meeting.ID)
+		assert.Equal(t, concurrent.StageParticipants, letters[0].Stage)
+		assert.Equal(t, []string{
+			"forced participants error for 13: unexpected EOF",
+			"forced participants error for 13: unexpected EOF",
+			"unexpected EOF",
+		}, letters[0].Errors)
+		assert.False(t, letters[0].FailedAt.IsZero())
+	}
+
+	healed.Store(true)
+	require.NoError(t, p.Replay(ctx, p.DeadLetters))
+
+	letters, err = p.DeadLetters.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+	calls := store.CreateMeetingDatumCalls()
+	assert.Len(t, calls, 20)
+	assert.Equal(t, "Meeting 13", calls[len(calls)-1].Args.Topic)
+}
+
+func TestFileDeadLetterStore(t *testing.T) {
+	ctx := context.Background()
+	s := &concurrent.FileDeadLetterStore{Path: filepath.Join(t.TempDir(), "dead-letters.jsonl")}
+
+	// Longer than a bufio.Scanner line
+	long := strings.Repeat("x", 1<<17)
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, s.Add(ctx, concurrent.DeadLetter{
+			Meeting: concurrent.Meeting{ID: id},
+			Stage:   concurrent.StageUpload,
+			Errors:  []string{long},
+		}))
+	}
+
+	letters, err := s.List(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, letters, 3) {
+		assert.Equal(t, long, letters[2].Errors[0])
+	}
+
+	require.NoError(t, s.Remove(ctx, []string{"2"}))
+	letters, err = s.List(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, letters, 2) {
+		assert.Equal(t, "1", letters[0].Meeting.ID)
+		assert.Equal(t, "3", letters[1].Meeting.ID)
+	}
+}
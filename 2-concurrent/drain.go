@@ -0,0 +1,60 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrDrainTimeout is the cause of a graceful stop that ran out of
+// Config.DrainGrace before every enriched meeting was uploaded.
+var ErrDrainTimeout = errors.New("drain grace period expired")
+
+// errDraining cancels the listing and the transform stage once stop is done
+var errDraining = errors.New("draining")
+
+// ProcessGraceful is like Process, but stops gracefully once stop is done:
+// no more meetings are listed or enriched, and the ones already enriched
+// get Config.DrainGrace to finish uploading before the run is cancelled
+// with ErrDrainTimeout. It returns the watermark reached after draining,
+// and no error if draining finished in time. Cancelling ctx still stops
+// everything at once.
+func (p *Processor) ProcessGraceful(ctx, stop context.Context) (time.Time, error) {
+	return p.process(ctx, stop, nil)
+}
+
+// drain returns a context that is cancelled once stop is done, or ctx is,
+// and starts the grace period then. When it expires, cancel is called with
+// ErrDrainTimeout. A nil stop never drains.
+func (p *Processor) drain(ctx, stop context.Context, cancel context.CancelCauseFunc) context.Context {
+	if stop == nil {
+		return ctx
+	}
+
+	draining, stopAccepting := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-stop.Done():
+		case <-ctx.Done():
+			return
+		}
+
+		stopAccepting(errDraining)
+		p.log(ctx, slog.LevelInfo, "draining", slog.Duration("grace", p.Cfg.DrainGrace))
+
+		if p.Cfg.DrainGrace <= 0 {
+			return
+		}
+
+		t := time.NewTimer(p.Cfg.DrainGrace)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel(ErrDrainTimeout)
+		case <-ctx.Done():
+		}
+	}()
+
+	return draining
+}
@@ -0,0 +1,87 @@
+package concurrent_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessGraceful(t *testing.T) {
+	newProcessor := func(store func(ctx context.Context, args concurrent.CreateMeetingDatumArguments) error) *concurrent.Processor {
+		client, mock := newMocks(50)
+		mock.CreateMeetingDatumFunc = store
+		return &concurrent.Processor{
+			Client: client,
+			Store:  mock,
+			Cfg: concurrent.Config{
+				TransformerConcurrency: 2,
+				UploaderConcurrency:    2,
+				DrainGrace:             time.Second,
+			},
+		}
+	}
+
+	t.Run("drains", func(t *testing.T) {
+		stop, stopAccepting := context.WithCancel(context.Background())
+		defer stopAccepting()
+
+		var mu sync.Mutex
+		uploaded := make(map[int]bool)
+		p := newProcessor(func(ctx context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			if args.Topic == "Meeting 4" {
+				stopAccepting()
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			// Uploads in flight are never cut short
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			n, _ := strconv.Atoi(strings.TrimPrefix(args.Topic, "Meeting "))
+			uploaded[n] = true
+			return nil
+		})
+
+		got, gerr := p.ProcessGraceful(context.Background(), stop)
+
+		assert.NoError(t, gerr)
+		assert.Less(t, len(uploaded), 50)
+
+		// The watermark covers exactly the meetings uploaded before the
+		// first one that was drained
+		days := got.YearDay()
+		assert.GreaterOrEqual(t, days, 4)
+		for i := 1; i <= days; i++ {
+			assert.True(t, uploaded[i], "meeting %d", i)
+		}
+		assert.False(t, uploaded[days+1])
+	})
+
+	t.Run("grace expires", func(t *testing.T) {
+		stop, stopAccepting := context.WithCancel(context.Background())
+		defer stopAccepting()
+
+		p := newProcessor(func(ctx context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			stopAccepting()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		p.Cfg.DrainGrace = 20 * time.Millisecond
+
+		start := time.Now()
+		got, gerr := p.ProcessGraceful(context.Background(), stop)
+
+		assert.ErrorIs(t, gerr, concurrent.ErrDrainTimeout)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		assert.True(t, got.IsZero())
+	})
+}
@@ -0,0 +1,105 @@
+package concurrent
+
+import (
+	"context"
+	"time"
+)
+
+// Event reports progress during a Run. It is one of PageFetched,
+// MeetingListed, EnrichStarted, EnrichFinished, Uploaded, Failed,
+// WatermarkAdvanced or Finished.
+type Event interface {
+	event()
+}
+
+// PageFetched is sent for every page of the listing.
+type PageFetched struct {
+	Meetings      int
+	NextPageToken string
+}
+
+// MeetingListed is sent when a meeting is handed to the transform stage.
+type MeetingListed struct {
+	Meeting Meeting
+}
+
+// EnrichStarted is sent before a meeting's recording and participants are
+// requested.
+type EnrichStarted struct {
+	Meeting Meeting
+}
+
+// EnrichFinished is sent once both requests have returned. Err is set if
+// either failed.
+type EnrichFinished struct {
+	Meeting  Meeting
+	Duration time.Duration
+	Err      error
+}
+
+// Uploaded is sent once a meeting has been stored.
+type Uploaded struct {
+	Meeting  Meeting
+	Duration time.Duration
+}
+
+// Failed is sent for a meeting that could not be processed. Skipped
+// meetings no longer exist upstream and don't hold the watermark back.
+type Failed struct {
+	Meeting Meeting
+	Failure MeetingFailure
+	Skipped bool
+}
+
+// WatermarkAdvanced is sent whenever the watermark moves forward and has
+// been checkpointed.
+type WatermarkAdvanced struct {
+	Watermark time.Time
+}
+
+// Finished is the last event of a Run, with what Process would return.
+type Finished struct {
+	Watermark time.Time
+	Err       error
+}
+
+func (PageFetched) event()       {}
+func (MeetingListed) event()     {}
+func (EnrichStarted) event()     {}
+func (EnrichFinished) event()    {}
+func (Uploaded) event()          {}
+func (Failed) event()            {}
+func (WatermarkAdvanced) event() {}
+func (Finished) event()          {}
+
+// Run is like Process, but reports its progress on the returned channel as
+// it goes. The channel ends with a Finished event and is then closed.
+//
+// Events are sent from the pipeline's goroutines, which wait for the
+// reader, so the channel must be read until it is closed or ctx is done.
+// Once ctx is done, events nobody reads are dropped, Finished included.
+func (p *Processor) Run(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	emit := func(e Event) {
+		select {
+		case events <- e:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+		t, err := p.process(ctx, nil, emit)
+		emit(Finished{Watermark: t, Err: err})
+	}()
+
+	return events
+}
+
+// event logs e and sends it to the Run in progress, if any.
+func (p *Processor) event(ctx context.Context, e Event) {
+	p.logEvent(ctx, e)
+	if emit := runStateFrom(ctx).emit; emit != nil {
+		emit(e)
+	}
+}
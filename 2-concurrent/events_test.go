@@ -0,0 +1,110 @@
+package concurrent_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	client, store := newMocks(5)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		if meetingID == "3" {
+			return nil, errors.New("forced participants error")
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 2,
+			UploaderConcurrency:    2,
+			ContinueOnError:        true,
+			PageSize:               3,
+		},
+	}
+
+	var events []concurrent.Event
+	for e := range p.Run(context.Background()) {
+		events = append(events, e)
+	}
+
+	counts := make(map[string]int)
+	var watermarks []time.Time
+	for _, e := range events {
+		switch e := e.(type) {
+		case concurrent.PageFetched:
+			counts["page"]++
+		case concurrent.MeetingListed:
+			counts["listed"]++
+		case concurrent.EnrichStarted:
+			counts["enrich started"]++
+		case concurrent.EnrichFinished:
+			counts["enrich finished"]++
+			if e.Err != nil {
+				counts["enrich failed"]++
+			}
+		case concurrent.Uploaded:
+			counts["uploaded"]++
+		case concurrent.Failed:
+			assert.Equal(t, "3", e.Failure.MeetingID)
+			assert.Equal(t, "Meeting 3", e.Meeting.Topic)
+			assert.Equal(t, concurrent.StageParticipants, e.Failure.Stage)
+			counts["failed"]++
+		case concurrent.WatermarkAdvanced:
+			watermarks = append(watermarks, e.Watermark)
+		}
+	}
+
+	assert.Equal(t, map[string]int{
+		"page":            2,
+		"listed":          5,
+		"enrich started":  5,
+		"enrich finished": 5,
+		"enrich failed":   1,
+		"uploaded":        4,
+		"failed":          1,
+	}, counts)
+	assert.Equal(t, []time.Time{
+		time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC),
+	}, watermarks)
+
+	if assert.IsType(t, concurrent.Finished{}, events[len(events)-1]) {
+		finished := events[len(events)-1].(concurrent.Finished)
+		assert.Equal(t, watermarks[len(watermarks)-1], finished.Watermark)
+		var report *concurrent.RunReport
+		assert.ErrorAs(t, finished.Err, &report)
+	}
+}
+
+func TestRunAbandoned(t *testing.T) {
+	client, store := newMocks(20)
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 2,
+			UploaderConcurrency:    2,
+		},
+	}
+	before := runtime.NumGoroutine()
+
+	// Read one event, then give up on the run without draining the channel
+	ctx, cancel := context.WithCancel(context.Background())
+	<-p.Run(ctx)
+	cancel()
+
+	// The run's goroutines give up on the events nobody reads
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, before, runtime.NumGoroutine())
+}
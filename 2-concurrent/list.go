@@ -0,0 +1,308 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Meetings buffered per shard when Config.PageSize is unset
+const defaultShardBuffer = 100
+
+// ErrPaginationLoop is returned when the listing hands back a page token it
+// has already handed back, or runs past Config.MaxPages or
+// Config.MaxMeetings.
+var ErrPaginationLoop = errors.New("pagination loop")
+
+// ErrInvalidWindow is returned before anything is listed when Config.From
+// isn't before Config.To, or the checkpoint is already past Config.To.
+var ErrInvalidWindow = errors.New("invalid listing window")
+
+// pageBudget counts the pages requested in a run, across shards.
+type pageBudget struct {
+	max   int
+	pages atomic.Int64
+}
+
+func (b *pageBudget) take() error {
+	if n := b.pages.Add(1); b.max > 0 && n > int64(b.max) {
+		return fmt.Errorf("%w: more than %d pages listed", ErrPaginationLoop, b.max)
+	}
+	return nil
+}
+
+// produce lists meetings starting at from. The zero time lists everything.
+// The meeting at from itself may be listed and uploaded again. Meetings
+// listed behind a later one are added to late.
+func (p *Processor) produce(ctx context.Context, from time.Time, late *lateMeetings, out chan<- datum) error {
+	params := p.listParams(from)
+
+	var seq int
+	guard := p.newOrderGuard(func(m Meeting, isLate bool) error {
+		d := datum{seq: seq, meeting: m}
+		p.startSpan(ctx, &d)
+		if isLate {
+			late.add(seq, m.Start)
+		}
+		select {
+		case out <- d:
+			seq++
+			p.Metrics.listed()
+			p.event(ctx, MeetingListed{Meeting: m})
+			return nil
+		case <-ctx.Done():
+			d.endSpan(ctx, nil)
+			return ctx.Err()
+		}
+	})
+
+	var listed int
+	add := func(m Meeting) error {
+		listed++
+		if p.Cfg.MaxMeetings > 0 && listed > p.Cfg.MaxMeetings {
+			return fmt.Errorf("%w: more than %d meetings listed", ErrPaginationLoop, p.Cfg.MaxMeetings)
+		}
+		return guard.add(m)
+	}
+
+	budget := &pageBudget{max: p.Cfg.MaxPages}
+
+	var err error
+	if p.Cfg.ListShards > 1 && params.From != nil && params.To != nil {
+		err = p.listSharded(ctx, params, budget, add)
+	} else {
+		err = p.listPages(ctx, params, budget, add)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			p.Metrics.listFailed(err)
+		}
+		return err
+	}
+
+	return guard.flush()
+}
+
+// checkWindow reports whether there is anything to list between from, the
+// resume point, and Config.To.
+func (p *Processor) checkWindow(from time.Time) error {
+	if p.Cfg.To.IsZero() {
+		return nil
+	}
+	if !p.Cfg.From.IsZero() && !p.Cfg.From.Before(p.Cfg.To) {
+		return fmt.Errorf("%w: From %s is not before To %s", ErrInvalidWindow, p.Cfg.From, p.Cfg.To)
+	}
+	if from.After(p.Cfg.To) {
+		return fmt.Errorf("%w: checkpoint %s is past To %s", ErrInvalidWindow, from, p.Cfg.To)
+	}
+	return nil
+}
+
+// listParams returns the parameters shared by every page request. Listing
+// starts at the later of from, the resume point, and Config.From.
+func (p *Processor) listParams(from time.Time) ListPaginatedMeetingsParams {
+	var params ListPaginatedMeetingsParams
+
+	if p.Cfg.From.After(from) {
+		from = p.Cfg.From
+	}
+	if !from.IsZero() {
+		params.From = &from
+	}
+
+	if !p.Cfg.To.IsZero() {
+		to := p.Cfg.To
+		params.To = &to
+	}
+
+	if p.Cfg.PageSize > 0 {
+		size := p.Cfg.PageSize
+		params.PageSize = &size
+	}
+
+	return params
+}
+
+// listPages walks every page of the listing described by base. With
+// Config.PrefetchPages set, the next pages are requested while the current
+// one is still being emitted.
+func (p *Processor) listPages(ctx context.Context, base ListPaginatedMeetingsParams, budget *pageBudget, emit func(Meeting) error) error {
+	emitPage := func(meetings []Meeting) error {
+		for _, meeting := range meetings {
+			if err := emit(meeting); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if p.Cfg.PrefetchPages <= 0 {
+		return p.fetchPages(ctx, base, budget, emitPage)
+	}
+
+	g, fetchCtx := errgroup.WithContext(ctx)
+
+	// The fetcher holds one more page while it waits for room
+	pages := make(chan []Meeting, p.Cfg.PrefetchPages-1)
+
+	g.Go(func() error {
+		defer close(pages)
+		return p.fetchPages(fetchCtx, base, budget, func(meetings []Meeting) error {
+			select {
+			case pages <- meetings:
+				p.Metrics.channelAdd(metricChannelPrefetch, 1)
+				return nil
+			case <-fetchCtx.Done():
+				return fetchCtx.Err()
+			}
+		})
+	})
+
+	g.Go(func() error {
+		for meetings := range pages {
+			p.Metrics.channelAdd(metricChannelPrefetch, -1)
+			if err := emitPage(meetings); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	err := g.Wait()
+	// Pages left behind by a failed listing are dropped
+	p.Metrics.channelAdd(metricChannelPrefetch, -len(pages))
+	return err
+}
+
+// fetchPages requests the pages of the listing one after the other, since
+// each request needs the token from the previous response. A token that
+// comes back a second time would loop forever, so it ends the listing with
+// ErrPaginationLoop.
+func (p *Processor) fetchPages(ctx context.Context, base ListPaginatedMeetingsParams, budget *pageBudget, page func([]Meeting) error) error {
+	var nextPageToken string
+	seen := make(map[string]bool)
+
+	// Handle pagination
+	for {
+		if err := budget.take(); err != nil {
+			return err
+		}
+
+		// Each request gets its own copy of the token
+		params := base
+		token := nextPageToken
+		params.NextPageToken = &token
+		resp, err := p.listMeetings(ctx, &params)
+		if err != nil {
+			return err
+		}
+		p.event(ctx, PageFetched{Meetings: len(resp.Meetings), NextPageToken: resp.NextPageToken})
+
+		if err := page(resp.Meetings); err != nil {
+			return err
+		}
+
+		if resp.NextPageToken == "" {
+			return nil
+		}
+
+		if seen[resp.NextPageToken] {
+			return fmt.Errorf("%w: page token %q returned twice", ErrPaginationLoop, resp.NextPageToken)
+		}
+		seen[resp.NextPageToken] = true
+
+		nextPageToken = resp.NextPageToken
+	}
+}
+
+// listSharded splits the listing window into Config.ListShards consecutive
+// time ranges and lists them concurrently. The shards don't overlap, so
+// emitting them one after the other keeps meetings ordered by Start time.
+// Shards that are listed ahead of their turn buffer up to a page of
+// meetings before they wait.
+func (p *Processor) listSharded(ctx context.Context, base ListPaginatedMeetingsParams, budget *pageBudget, emit func(Meeting) error) error {
+	from, to := *base.From, *base.To
+	n := p.Cfg.ListShards
+
+	bounds := make([]time.Time, n+1)
+	for i := range bounds {
+		bounds[i] = from.Add(to.Sub(from) / time.Duration(n) * time.Duration(i))
+	}
+	bounds[n] = to
+
+	buffer := p.Cfg.PageSize
+	if buffer <= 0 {
+		buffer = defaultShardBuffer
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	shards := make([]chan Meeting, n)
+	for i := range shards {
+		shard := make(chan Meeting, buffer)
+		shards[i] = shard
+
+		params := base
+		params.From = &bounds[i]
+		params.To = &bounds[i+1]
+
+		g.Go(func() error {
+			err := p.listPages(ctx, params, budget, func(m Meeting) error {
+				select {
+				case shard <- m:
+					p.Metrics.channelAdd(metricChannelShards, 1)
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if err != nil {
+				// Leave the shard open, so the merge can't mistake a
+				// failed shard for a complete one and move on
+				return err
+			}
+			close(shard)
+			return nil
+		})
+	}
+
+	// Merge
+	g.Go(func() error {
+		for i, shard := range shards {
+			for {
+				var m Meeting
+				var ok bool
+				select {
+				case m, ok = <-shard:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if !ok {
+					break
+				}
+				p.Metrics.channelAdd(metricChannelShards, -1)
+
+				// Drop meetings the API returned outside the shard, e.g.
+				// because it treats both ends of the range as inclusive
+				if m.Start.Before(bounds[i]) || (i < n-1 && !m.Start.Before(bounds[i+1])) {
+					continue
+				}
+
+				if err := emit(m); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	err := g.Wait()
+	for _, shard := range shards {
+		p.Metrics.channelAdd(metricChannelShards, -len(shard))
+	}
+	return err
+}
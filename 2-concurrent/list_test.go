@@ -0,0 +1,250 @@
+package concurrent_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"example.com/pipelines-and-cancellation/checkpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessOrder(t *testing.T) {
+	meeting := func(id int) concurrent.Meeting {
+		return concurrent.Meeting{
+			ID:    strconv.Itoa(id),
+			Topic: "Meeting " + strconv.Itoa(id),
+			Start: time.Date(2023, time.January, id, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	// 3 is listed twice, 4 is two places late
+	pages := [][]concurrent.Meeting{
+		{meeting(1), meeting(2), meeting(3)},
+		{meeting(3), meeting(5), meeting(6), meeting(4)},
+		{meeting(7)},
+	}
+
+	newProcessor := func(cfg concurrent.Config) *concurrent.Processor {
+		cfg.TransformerConcurrency = 1
+		cfg.UploaderConcurrency = 1
+		client, store := newMocks(0)
+		client.ListPaginatedMeetingsFunc = func(_ context.Context, params *concurrent.ListPaginatedMeetingsParams) (concurrent.ListPaginatedMeetingsResponse, error) {
+			i, _ := strconv.Atoi(*params.NextPageToken)
+			resp := concurrent.ListPaginatedMeetingsResponse{Meetings: pages[i]}
+			if i+1 < len(pages) {
+				resp.NextPageToken = strconv.Itoa(i + 1)
+			}
+			return resp, nil
+		}
+		return &concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg:    cfg,
+		}
+	}
+
+	uploaded := func(p *concurrent.Processor) []string {
+		var topics []string
+		for _, call := range p.Store.(*concurrent.StoreInterfaceMock).CreateMeetingDatumCalls() {
+			topics = append(topics, strings.TrimPrefix(call.Args.Topic, "Meeting "))
+		}
+		return topics
+	}
+
+	t.Run("fails by default", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{})
+
+		_, gerr := p.Process(context.Background())
+
+		var oerr *concurrent.OrderError
+		if assert.ErrorAs(t, gerr, &oerr) {
+			assert.Equal(t, []string{"3"}, oerr.Duplicates)
+		}
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		var mu sync.Mutex
+		var violations []*concurrent.OrderError
+		p := newProcessor(concurrent.Config{
+			OrderPolicy: concurrent.OrderSort,
+			OrderWindow: 2,
+			OnOrderViolation: func(err *concurrent.OrderError) {
+				mu.Lock()
+				defer mu.Unlock()
+				violations = append(violations, err)
+			},
+		})
+
+		got, gerr := p.Process(context.Background())
+
+		assert.NoError(t, gerr)
+		assert.Equal(t, meeting(7).Start, got)
+		assert.Equal(t, []string{"1", "2", "3", "4", "5", "6", "7"}, uploaded(p))
+		assert.Equal(t, []*concurrent.OrderError{
+			{Duplicates: []string{"3"}},
+			{OutOfOrder: []string{"4"}},
+		}, violations)
+	})
+
+	t.Run("sort window too small", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{
+			OrderPolicy: concurrent.OrderSort,
+		})
+
+		_, gerr := p.Process(context.Background())
+
+		var oerr *concurrent.OrderError
+		if assert.ErrorAs(t, gerr, &oerr) {
+			assert.Equal(t, []string{"4"}, oerr.OutOfOrder)
+		}
+	})
+
+	t.Run("clamp", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{
+			OrderPolicy: concurrent.OrderClamp,
+		})
+
+		got, gerr := p.Process(context.Background())
+
+		assert.NoError(t, gerr)
+		assert.Equal(t, meeting(7).Start, got)
+		assert.Equal(t, []string{"1", "2", "3", "5", "6", "4", "7"}, uploaded(p))
+	})
+
+	t.Run("clamp late failure", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{
+			OrderPolicy:     concurrent.OrderClamp,
+			ContinueOnError: true,
+		})
+		store := p.Store.(*concurrent.StoreInterfaceMock)
+		store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			if args.Topic == "Meeting 4" {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+
+		got, gerr := p.Process(context.Background())
+
+		assert.ErrorIs(t, gerr, io.ErrUnexpectedEOF)
+		assert.False(t, got.After(meeting(4).Start))
+	})
+}
+
+func TestProcessPagination(t *testing.T) {
+	newProcessor := func(cfg concurrent.Config, next func(token string) string) *concurrent.Processor {
+		cfg.TransformerConcurrency = 1
+		cfg.UploaderConcurrency = 1
+		client, store := newMocks(0)
+		client.ListPaginatedMeetingsFunc = func(_ context.Context, params *concurrent.ListPaginatedMeetingsParams) (concurrent.ListPaginatedMeetingsResponse, error) {
+			i, _ := strconv.Atoi(*params.NextPageToken)
+			return concurrent.ListPaginatedMeetingsResponse{
+				Meetings: []concurrent.Meeting{{
+					ID:    strconv.Itoa(i),
+					Start: time.Date(2023, time.January, 1, i, 0, 0, 0, time.UTC),
+				}},
+				NextPageToken: next(*params.NextPageToken),
+			}, nil
+		}
+		return &concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg:    cfg,
+		}
+	}
+
+	// Never runs out of pages
+	endless := func(token string) string {
+		i, _ := strconv.Atoi(token)
+		return strconv.Itoa(i + 1)
+	}
+
+	t.Run("token cycle", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{}, func(token string) string {
+			if token == "2" {
+				return "1"
+			}
+			return endless(token)
+		})
+
+		_, gerr := p.Process(context.Background())
+
+		assert.ErrorIs(t, gerr, concurrent.ErrPaginationLoop)
+		assert.Len(t, p.Client.(*concurrent.ClientInterfaceMock).ListPaginatedMeetingsCalls(), 3)
+	})
+
+	t.Run("max pages", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{MaxPages: 5}, endless)
+
+		_, gerr := p.Process(context.Background())
+
+		assert.ErrorIs(t, gerr, concurrent.ErrPaginationLoop)
+		assert.Len(t, p.Client.(*concurrent.ClientInterfaceMock).ListPaginatedMeetingsCalls(), 5)
+	})
+
+	t.Run("max meetings", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{MaxMeetings: 3}, endless)
+
+		got, gerr := p.Process(context.Background())
+
+		assert.ErrorIs(t, gerr, concurrent.ErrPaginationLoop)
+		assert.False(t, got.After(time.Date(2023, time.January, 1, 2, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestProcessInvalidWindow(t *testing.T) {
+	from := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	newProcessor := func(cfg concurrent.Config, resume time.Time) *concurrent.Processor {
+		cp := &checkpoint.FileStore{Path: filepath.Join(t.TempDir(), "watermark")}
+		if !resume.IsZero() {
+			assert.NoError(t, cp.Save(context.Background(), resume))
+		}
+		client, store := newMocks(10)
+		cfg.TransformerConcurrency = 1
+		cfg.UploaderConcurrency = 1
+		cfg.ListShards = 4
+		return &concurrent.Processor{
+			Client:     client,
+			Store:      store,
+			Cfg:        cfg,
+			Checkpoint: cp,
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		cfg    concurrent.Config
+		resume time.Time
+	}{
+		"from after to":                  {cfg: concurrent.Config{From: to, To: from}},
+		"empty":                          {cfg: concurrent.Config{From: from, To: from}},
+		"checkpoint past to":             {cfg: concurrent.Config{From: from, To: to}, resume: to.Add(time.Hour)},
+		"checkpoint past to, open start": {cfg: concurrent.Config{To: to}, resume: to.Add(time.Hour)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newProcessor(tc.cfg, tc.resume)
+
+			got, gerr := p.Process(context.Background())
+
+			assert.ErrorIs(t, gerr, concurrent.ErrInvalidWindow)
+			assert.True(t, tc.resume.Equal(got))
+			assert.Empty(t, p.Client.(*concurrent.ClientInterfaceMock).ListPaginatedMeetingsCalls())
+		})
+	}
+
+	t.Run("checkpoint at to", func(t *testing.T) {
+		p := newProcessor(concurrent.Config{From: from, To: to}, to)
+
+		_, gerr := p.Process(context.Background())
+
+		assert.NoError(t, gerr)
+	})
+}
@@ -0,0 +1,153 @@
+package concurrent
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Log levels, from the Processor's point of view:
+//   - Debug: stage transitions of every meeting, meetings cancelled with
+//     the run, pages and the watermark
+//   - Info: runs starting and finishing, skipped meetings
+//   - Warn: retries and cancelled runs
+//   - Error: failed meetings and runs
+//
+// Choose which are written with the level of the Logger's handler, e.g.
+// slog.HandlerOptions.Level, which may be a *slog.LevelVar to change it
+// while running.
+
+// Attribute keys shared by every log line about a meeting
+const (
+	logKeyMeetingID = "meeting_id"
+	logKeyTopic     = "topic"
+	logKeyStart     = "start"
+	logKeyStage     = "stage"
+	logKeyAttempt   = "attempt"
+)
+
+// LogValue redacts the email address, so that a participant logged as an
+// attribute doesn't show it in clear text. The processor itself never logs
+// participants.
+func (pt Participant) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", pt.ID),
+		slog.String("name", pt.Name),
+		slog.String("email", redactEmail(pt.Email)),
+	)
+}
+
+// redactEmail keeps only the domain of an email address.
+func redactEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+	if at := strings.LastIndexByte(email, '@'); at >= 0 {
+		return "***" + email[at:]
+	}
+	return "***"
+}
+
+func meetingLogAttrs(m Meeting) []slog.Attr {
+	return []slog.Attr{
+		slog.String(logKeyMeetingID, m.ID),
+		slog.String(logKeyTopic, m.Topic),
+		slog.Time(logKeyStart, m.Start),
+	}
+}
+
+// log is a no-op without a Logger.
+func (p *Processor) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if p.Logger != nil {
+		p.Logger.LogAttrs(ctx, level, msg, attrs...)
+	}
+}
+
+// logEvent logs what e reports.
+func (p *Processor) logEvent(ctx context.Context, e Event) {
+	switch e := e.(type) {
+	case PageFetched:
+		p.log(ctx, slog.LevelDebug, "page fetched", slog.Int("meetings", e.Meetings))
+
+	case MeetingListed:
+		p.log(ctx, slog.LevelDebug, "meeting listed", meetingLogAttrs(e.Meeting)...)
+
+	case EnrichStarted:
+		p.log(ctx, slog.LevelDebug, "enrich started",
+			append(meetingLogAttrs(e.Meeting), slog.String(logKeyStage, metricStageTransform))...)
+
+	case EnrichFinished:
+		attrs := append(meetingLogAttrs(e.Meeting),
+			slog.String(logKeyStage, metricStageTransform),
+			slog.Duration("duration", e.Duration))
+		if e.Err != nil {
+			attrs = append(attrs, slog.Any("error", e.Err))
+		}
+		p.log(ctx, slog.LevelDebug, "enrich finished", attrs...)
+
+	case Uploaded:
+		p.log(ctx, slog.LevelDebug, "meeting uploaded",
+			append(meetingLogAttrs(e.Meeting),
+				slog.String(logKeyStage, string(StageUpload)),
+				slog.Duration("duration", e.Duration))...)
+
+	case Failed:
+		f := e.Failure
+		attrs := append(meetingLogAttrs(e.Meeting),
+			slog.String(logKeyStage, string(f.Stage)),
+			slog.Int(logKeyAttempt, f.Attempts),
+			slog.Any("error", f.Err))
+		if e.Skipped {
+			p.log(ctx, slog.LevelInfo, "meeting skipped, no longer exists", attrs...)
+		} else {
+			p.log(ctx, slog.LevelError, "meeting failed", attrs...)
+		}
+
+	case WatermarkAdvanced:
+		p.log(ctx, slog.LevelDebug, "watermark advanced", slog.Time("watermark", e.Watermark))
+	}
+}
+
+// logRetry returns a retry.Policy.OnRetry that logs retries of c for m,
+// if any, before calling next.
+func (p *Processor) logRetry(ctx context.Context, c Call, m *Meeting, next func(int, error, time.Duration)) func(int, error, time.Duration) {
+	if p.Logger == nil {
+		return next
+	}
+
+	var attrs []slog.Attr
+	if m != nil {
+		attrs = meetingLogAttrs(*m)
+	}
+	attrs = append(attrs, slog.String("call", string(c)))
+
+	return func(attempt int, err error, delay time.Duration) {
+		p.log(ctx, slog.LevelWarn, "retrying call",
+			append(attrs[:len(attrs):len(attrs)],
+				slog.Int(logKeyAttempt, attempt),
+				slog.Duration("delay", delay),
+				slog.Any("error", err))...)
+		if next != nil {
+			next(attempt, err, delay)
+		}
+	}
+}
+
+// logRun logs the outcome of a run started at from.
+func (p *Processor) logRun(ctx context.Context, from, watermark time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.Time("from", from),
+		slog.Time("watermark", watermark),
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		p.log(ctx, slog.LevelWarn, "run cancelled",
+			append(attrs, slog.Any("cause", context.Cause(ctx)))...)
+	case err != nil:
+		p.log(ctx, slog.LevelError, "run failed", append(attrs, slog.Any("error", err))...)
+	default:
+		p.log(ctx, slog.LevelInfo, "run finished", attrs...)
+	}
+}
@@ -0,0 +1,138 @@
+package concurrent_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer lets the handler write from the pipeline's goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines(t *testing.T) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var m map[string]any
+		if assert.NoError(t, json.Unmarshal([]byte(line), &m)) {
+			lines = append(lines, m)
+		}
+	}
+	return lines
+}
+
+func TestLogging(t *testing.T) {
+	participants := []concurrent.Participant{{ID: "p1", Name: "Alice", Email: "alice@example.com"}}
+
+	newProcessor := func(level slog.Level) (*concurrent.Processor, *syncBuffer) {
+		var out syncBuffer
+		var participantCalls atomic.Int64
+		client, store := newMocks(4)
+		client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+			switch {
+			case meetingID == "2" && participantCalls.Add(1) == 1:
+				return nil, fault.Transient(errors.New("forced participants error"))
+			case meetingID == "3":
+				return nil, fault.NotFound(errors.New("meeting was deleted"))
+			}
+			return participants, nil
+		}
+		store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			if args.Topic == "Meeting 4" {
+				return fault.Permanent(errors.New("forced store error"))
+			}
+			return nil
+		}
+		return &concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg: concurrent.Config{
+				TransformerConcurrency: 1,
+				UploaderConcurrency:    1,
+				ContinueOnError:        true,
+				Retry:                  retry.Policy{MaxAttempts: 2},
+			},
+			Logger: slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: level})),
+		}, &out
+	}
+
+	t.Run("debug", func(t *testing.T) {
+		p, out := newProcessor(slog.LevelDebug)
+		_, gerr := p.Process(context.Background())
+		assert.Error(t, gerr)
+
+		// A participant logged by anyone is redacted too
+		p.Logger.Info("participant", "participant", participants[0])
+
+		assert.NotContains(t, out.buf.String(), "alice@")
+
+		find := func(msg string) map[string]any {
+			for _, line := range out.lines(t) {
+				if line["msg"] == msg {
+					return line
+				}
+			}
+			t.Errorf("no %q in logs", msg)
+			return nil
+		}
+
+		retried := find("retrying call")
+		assert.Equal(t, "WARN", retried["level"])
+		assert.Equal(t, "2", retried["meeting_id"])
+		assert.Equal(t, "Meeting 2", retried["topic"])
+		assert.Equal(t, "2023-01-02T00:00:00Z", retried["start"])
+		assert.Equal(t, float64(1), retried["attempt"])
+
+		skipped := find("meeting skipped, no longer exists")
+		assert.Equal(t, "3", skipped["meeting_id"])
+		assert.Equal(t, "Meeting 3", skipped["topic"])
+		assert.Equal(t, "2023-01-03T00:00:00Z", skipped["start"])
+		assert.Equal(t, "participants", skipped["stage"])
+
+		failed := find("meeting failed")
+		assert.Equal(t, "ERROR", failed["level"])
+		assert.Equal(t, "4", failed["meeting_id"])
+		assert.Equal(t, "Meeting 4", failed["topic"])
+		assert.Equal(t, "2023-01-04T00:00:00Z", failed["start"])
+		assert.Equal(t, "upload", failed["stage"])
+
+		assert.Equal(t, "ERROR", find("run failed")["level"])
+		started := find("enrich started")
+		assert.Equal(t, "1", started["meeting_id"])
+		assert.Equal(t, "transform", started["stage"])
+		assert.Equal(t, "transform", find("enrich finished")["stage"])
+		assert.Equal(t, map[string]any{
+			"id":    "p1",
+			"name":  "Alice",
+			"email": "***@example.com",
+		}, find("participant")["participant"])
+	})
+
+	t.Run("info", func(t *testing.T) {
+		p, out := newProcessor(slog.LevelInfo)
+		p.Process(context.Background())
+
+		for _, line := range out.lines(t) {
+			assert.NotEqual(t, "DEBUG", line["level"])
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"example.com/pipelines-and-cancellation/breaker"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/metrics"
+)
+
+// Metrics collects what a Processor is doing, in the Prometheus text
+// exposition format. Serve it over HTTP to have it scraped. One Metrics
+// can be shared by successive runs.
+type Metrics struct {
+	*metrics.Registry
+
+	stageIn       *metrics.CounterVec
+	stageOut      *metrics.CounterVec
+	stageErrors   *metrics.CounterVec
+	stageInFlight *metrics.GaugeVec
+
+	callDuration  *metrics.HistogramVec
+	callErrors    *metrics.CounterVec
+	callsInFlight *metrics.GaugeVec
+
+	channelItems *metrics.GaugeVec
+	watermark    *metrics.GaugeVec
+}
+
+// NewMetrics returns Metrics with every series at zero.
+func NewMetrics() *Metrics {
+	r := metrics.NewRegistry()
+	return &Metrics{
+		Registry: r,
+
+		stageIn:       r.Counter("pipeline_stage_items_in_total", "Meetings that entered a stage.", "stage"),
+		stageOut:      r.Counter("pipeline_stage_items_out_total", "Meetings that left a stage successfully.", "stage"),
+		stageErrors:   r.Counter("pipeline_stage_errors_total", "Meetings that failed in a stage, by error class.", "stage", "class"),
+		stageInFlight: r.Gauge("pipeline_stage_in_flight", "Meetings being worked on in a stage.", "stage"),
+
+		callDuration:  r.Histogram("pipeline_call_duration_seconds", "Latency of client and store calls, per attempt.", metrics.DefBuckets, "call"),
+		callErrors:    r.Counter("pipeline_call_errors_total", "Failed client and store calls, per attempt, by error class.", "call", "class"),
+		callsInFlight: r.Gauge("pipeline_calls_in_flight", "Client and store calls in progress.", "call"),
+
+		channelItems: r.Gauge("pipeline_channel_items", "Items waiting in the listing's buffered channels and the reorder buffer.", "channel"),
+		watermark:    r.Gauge("pipeline_watermark_seconds", "Start time of the last meeting committed, as a Unix timestamp."),
+	}
+}
+
+// Names of the stages and channels in the metrics
+const (
+	metricStageList      = "list"
+	metricStageTransform = "transform"
+	metricStageUpload    = "upload"
+
+	metricChannelPrefetch = "prefetch"
+	metricChannelShards   = "shards"
+	metricChannelReorder  = "reorder"
+)
+
+// errorClass names the kind of err, for metric labels.
+func errorClass(err error) string {
+	switch {
+	case fault.IsTimeout(err):
+		return "timeout"
+	case errors.Is(err, breaker.ErrOpen):
+		return "circuit_open"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case fault.IsRateLimited(err):
+		return "rate_limited"
+	case fault.IsNotFound(err):
+		return "not_found"
+	case fault.IsPermanent(err):
+		return "permanent"
+	case fault.IsTransient(err):
+		return "transient"
+	}
+	return "other"
+}
+
+// All methods below are no-ops on a nil *Metrics.
+
+func (m *Metrics) listed() {
+	if m != nil {
+		m.stageOut.With(metricStageList).Inc()
+	}
+}
+
+func (m *Metrics) listFailed(err error) {
+	if m != nil {
+		m.stageErrors.With(metricStageList, errorClass(err)).Inc()
+	}
+}
+
+func (m *Metrics) channelAdd(channel string, n int) {
+	if m != nil {
+		m.channelItems.With(channel).Add(float64(n))
+	}
+}
+
+func (m *Metrics) setWatermark(t time.Time) {
+	if m != nil && !t.IsZero() {
+		m.watermark.With().Set(float64(t.UnixNano()) / 1e9)
+	}
+}
+
+// startCall records that an attempt at c has started. Call the returned
+// function with its outcome.
+func (m *Metrics) startCall(c Call) func(err error) {
+	if m == nil {
+		return func(error) {}
+	}
+
+	inFlight := m.callsInFlight.With(string(c))
+	inFlight.Inc()
+	start := time.Now()
+
+	return func(err error) {
+		inFlight.Dec()
+		m.callDuration.With(string(c)).Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.callErrors.With(string(c), errorClass(err)).Inc()
+		}
+	}
+}
+
+// measure counts the meetings going in and out of stage fn. Meetings that
+// are passed through, see datum.passThrough, don't count.
+func (m *Metrics) measure(stage string, fn func(ctx context.Context, d datum) (datum, error)) func(ctx context.Context, d datum) (datum, error) {
+	if m == nil {
+		return fn
+	}
+
+	return func(ctx context.Context, d datum) (datum, error) {
+		if d.passThrough() {
+			return fn(ctx, d)
+		}
+
+		m.stageIn.With(stage).Inc()
+		inFlight := m.stageInFlight.With(stage)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		d, err := fn(ctx, d)
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				m.stageErrors.With(stage, errorClass(err)).Inc()
+			}
+		case d.fail != nil:
+			m.stageErrors.With(stage, errorClass(d.fail)).Inc()
+		default:
+			m.stageOut.With(stage).Inc()
+		}
+		return d, err
+	}
+}
@@ -0,0 +1,79 @@
+package concurrent_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	var downloads atomic.Int64
+
+	m := concurrent.NewMetrics()
+	client, store := newMocks(5)
+	client.DownloadMeetingFunc = func(_ context.Context, url string) (io.ReadCloser, error) {
+		if downloads.Add(1) == 1 {
+			return nil, fault.Transient(errors.New("forced download error"))
+		}
+		return readSeekCloser{strings.NewReader("content")}, nil
+	}
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		if args.Topic == "Meeting 4" {
+			return fault.Permanent(errors.New("forced store error"))
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 2,
+			UploaderConcurrency:    2,
+			UploaderUnordered:      true,
+			ContinueOnError:        true,
+			Retry:                  retry.Policy{MaxAttempts: 2},
+		},
+		Metrics: m,
+		DeadLetters: &concurrent.FileDeadLetterStore{
+			Path: filepath.Join(t.TempDir(), "dead-letters.jsonl"),
+		},
+	}
+
+	_, gerr := p.Process(context.Background())
+	var report *concurrent.RunReport
+	assert.ErrorAs(t, gerr, &report)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		`pipeline_stage_items_out_total{stage="list"} 5`,
+		`pipeline_stage_items_in_total{stage="transform"} 5`,
+		`pipeline_stage_items_out_total{stage="transform"} 5`,
+		`pipeline_stage_items_in_total{stage="upload"} 5`,
+		`pipeline_stage_items_out_total{stage="upload"} 4`,
+		`pipeline_stage_errors_total{stage="upload",class="permanent"} 1`,
+		`pipeline_stage_in_flight{stage="upload"} 0`,
+		`pipeline_call_duration_seconds_count{call="download"} 6`,
+		`pipeline_call_duration_seconds_count{call="list"} 1`,
+		`pipeline_call_errors_total{call="download",class="transient"} 1`,
+		`pipeline_call_errors_total{call="store",class="permanent"} 1`,
+		`pipeline_calls_in_flight{call="store"} 0`,
+		`pipeline_channel_items{channel="reorder"} 0`,
+		// 2023-01-05, the last meeting: the failure was dead-lettered
+		`pipeline_watermark_seconds 1.6728768e+09`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
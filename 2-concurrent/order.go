@@ -0,0 +1,234 @@
+package concurrent
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OrderPolicy decides what happens when ListPaginatedMeetings breaks its
+// contract of returning meetings ordered by Start time.
+type OrderPolicy int
+
+const (
+	// OrderFail aborts the run with an *OrderError. It is the default, so
+	// a listing that breaks the contract fails the run rather than
+	// silently moving the watermark past meetings that arrive late.
+	OrderFail OrderPolicy = iota
+
+	// OrderSort buffers up to Config.OrderWindow meetings and hands them on
+	// in Start order. Duplicates are dropped. A meeting that is too far out
+	// of order to be fixed aborts the run with an *OrderError.
+	OrderSort
+
+	// OrderClamp hands meetings on as they are listed, dropping
+	// duplicates. A meeting that is out of order doesn't pull the
+	// watermark back once it is uploaded; until then, the checkpoint is
+	// held at its Start so that a failure doesn't lose it.
+	OrderClamp
+)
+
+// OrderError reports meetings that broke the ordering contract.
+type OrderError struct {
+	// OutOfOrder lists meetings that started before a meeting listed
+	// earlier
+	OutOfOrder []string
+
+	// Duplicates lists meetings that were listed more than once
+	Duplicates []string
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("meetings not ordered by Start time: out of order %v, duplicates %v", e.OutOfOrder, e.Duplicates)
+}
+
+// orderGuard sits between the listing and the transform stage and applies
+// the OrderPolicy. Duplicates are only detected among meetings with the
+// latest Start time seen so far; earlier duplicates show up as out of order.
+type orderGuard struct {
+	policy  OrderPolicy
+	window  int
+	violate func(*OrderError)
+	emit    func(m Meeting, late bool) error
+
+	started   bool
+	lastStart time.Time
+	atLast    map[string]bool
+
+	// OrderSort only
+	buf      meetingHeap
+	buffered map[string]bool
+	arrivals int
+	prev     time.Time
+}
+
+func (p *Processor) newOrderGuard(emit func(m Meeting, late bool) error) *orderGuard {
+	window := p.Cfg.OrderWindow
+	if window < 1 {
+		window = 1
+	}
+
+	violate := p.Cfg.OnOrderViolation
+	if violate == nil {
+		violate = func(*OrderError) {}
+	}
+
+	return &orderGuard{
+		policy:   p.Cfg.OrderPolicy,
+		window:   window,
+		violate:  violate,
+		emit:     emit,
+		atLast:   make(map[string]bool),
+		buffered: make(map[string]bool),
+	}
+}
+
+func (o *orderGuard) add(m Meeting) error {
+	if o.policy != OrderSort {
+		return o.check(m)
+	}
+
+	if o.buffered[m.ID] || (o.started && m.Start.Equal(o.lastStart) && o.atLast[m.ID]) {
+		o.violate(&OrderError{Duplicates: []string{m.ID}})
+		return nil
+	}
+
+	if o.started && m.Start.Before(o.lastStart) {
+		// Older than a meeting already handed on
+		return &OrderError{OutOfOrder: []string{m.ID}}
+	}
+
+	if o.arrivals > 0 && m.Start.Before(o.prev) {
+		// Fixable, but still worth knowing about
+		o.violate(&OrderError{OutOfOrder: []string{m.ID}})
+	}
+	o.prev = m.Start
+
+	heap.Push(&o.buf, arrival{meeting: m, n: o.arrivals})
+	o.arrivals++
+	o.buffered[m.ID] = true
+
+	if len(o.buf) > o.window {
+		return o.pop()
+	}
+	return nil
+}
+
+// flush hands on whatever OrderSort still buffers.
+func (o *orderGuard) flush() error {
+	for len(o.buf) > 0 {
+		if err := o.pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *orderGuard) pop() error {
+	a := heap.Pop(&o.buf).(arrival)
+	delete(o.buffered, a.meeting.ID)
+	return o.check(a.meeting)
+}
+
+func (o *orderGuard) check(m Meeting) error {
+	if o.started {
+		switch {
+		case m.Start.Equal(o.lastStart) && o.atLast[m.ID]:
+			err := &OrderError{Duplicates: []string{m.ID}}
+			if o.policy == OrderFail {
+				return err
+			}
+			o.violate(err)
+			return nil
+
+		case m.Start.Before(o.lastStart):
+			err := &OrderError{OutOfOrder: []string{m.ID}}
+			if o.policy != OrderClamp {
+				return err
+			}
+			o.violate(err)
+			return o.emit(m, true)
+		}
+	}
+
+	if !o.started || m.Start.After(o.lastStart) {
+		o.started = true
+		o.lastStart = m.Start
+		o.atLast = make(map[string]bool)
+	}
+	o.atLast[m.ID] = true
+
+	return o.emit(m, false)
+}
+
+// lateMeetings holds the Start of every meeting OrderClamp let through
+// behind a later one, until it is committed. The watermark may already be
+// past them, so the resume point is held back to the earliest one that
+// hasn't been uploaded. It is safe for concurrent use.
+type lateMeetings struct {
+	mu     sync.Mutex
+	starts map[int]time.Time
+}
+
+func (l *lateMeetings) add(seq int, start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.starts == nil {
+		l.starts = make(map[int]time.Time)
+	}
+	l.starts[seq] = start
+}
+
+func (l *lateMeetings) remove(seq int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.starts, seq)
+}
+
+// hold returns t, or the earliest Start still held if that is before t.
+func (l *lateMeetings) hold(t time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, start := range l.starts {
+		if start.Before(t) {
+			t = start
+		}
+	}
+	return t
+}
+
+// arrival orders meetings by Start time, then by the order they were
+// listed in.
+type arrival struct {
+	meeting Meeting
+	n       int
+}
+
+type meetingHeap []arrival
+
+func (h meetingHeap) Len() int {
+	return len(h)
+}
+
+func (h meetingHeap) Less(i, j int) bool {
+	if h[i].meeting.Start.Equal(h[j].meeting.Start) {
+		return h[i].n < h[j].n
+	}
+	return h[i].meeting.Start.Before(h[j].meeting.Start)
+}
+
+func (h meetingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *meetingHeap) Push(x any) {
+	*h = append(*h, x.(arrival))
+}
+
+func (h *meetingHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
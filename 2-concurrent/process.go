@@ -2,10 +2,20 @@ package concurrent
 
 import (
 	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"time"
 
-	"github.com/sourcegraph/conc/stream"
+	"example.com/pipelines-and-cancellation/adaptive"
+	"example.com/pipelines-and-cancellation/breaker"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/hedge"
+	"example.com/pipelines-and-cancellation/pipeline"
+	"example.com/pipelines-and-cancellation/ratelimit"
+	"example.com/pipelines-and-cancellation/retry"
+	"example.com/pipelines-and-cancellation/trace"
+	"example.com/pipelines-and-cancellation/watermark"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -53,123 +63,550 @@ type CreateMeetingDatumArguments struct {
 
 //go:generate go run github.com/matryer/moq/... -out autogen_store.go . StoreInterface
 type StoreInterface interface {
+	// CreateMeetingDatum must not close args.Content; the processor owns
+	// it and closes it once the call returns
 	CreateMeetingDatum(ctx context.Context, args CreateMeetingDatumArguments) error
 }
 
+// ErrRunTimeout is the cause of a run that ran out of Config.RunTimeout. It
+// is classified as fault.ErrTimeout.
+var ErrRunTimeout = fault.Timeout(errors.New("run timed out"))
+
 type Config struct {
 	TransformerConcurrency int
 	UploaderConcurrency    int
+
+	// TransformerLimiter and UploaderLimiter, if set, replace the fixed
+	// concurrency of their stage with one that adapts to the latency and
+	// failures of its meetings, between the limiter's bounds. Keep a
+	// reference to watch the limit converge; it carries over between runs.
+	TransformerLimiter *adaptive.Limiter
+	UploaderLimiter    *adaptive.Limiter
+
+	// Unordered stages don't wait for slow meetings before passing on the
+	// ones behind them. Meetings are put back in listing order before the
+	// watermark is committed, buffering at most ReorderWindow meetings,
+	// which defaults to TransformerConcurrency + UploaderConcurrency.
+	TransformerUnordered bool
+	UploaderUnordered    bool
+	ReorderWindow        int
+
+	// From and To bound the meetings listed, for backfills. A checkpoint
+	// later than From takes precedence. Zero values are left unset. From
+	// must be before To, and a checkpoint past To fails the run with
+	// ErrInvalidWindow, before anything is listed.
+	From time.Time
+	To   time.Time
+
+	// PageSize is the number of meetings requested per page. Zero leaves
+	// it to the API.
+	PageSize int
+
+	// PrefetchPages is the number of pages requested ahead of the one
+	// being handed to the transform stage. Zero waits for each page to
+	// drain before requesting the next.
+	PrefetchPages int
+
+	// ListShards > 1 splits the From-To window into that many time shards
+	// that are listed concurrently, for large backfills. It only applies
+	// when both ends of the window are known.
+	ListShards int
+
+	// MaxPages and MaxMeetings cap how much a single run lists, across all
+	// shards, so an API that never stops paginating can't hang the job.
+	// Going over either fails the run with ErrPaginationLoop. Zero means
+	// no limit.
+	MaxPages    int
+	MaxMeetings int
+
+	// OrderPolicy decides what happens if meetings aren't listed in Start
+	// order. OrderWindow is the number of meetings OrderSort may buffer.
+	// OnOrderViolation, if set, is told about every violation that
+	// doesn't abort the run.
+	OrderPolicy      OrderPolicy
+	OrderWindow      int
+	OnOrderViolation func(*OrderError)
+
+	// Retry applies to every client and store call unless overridden
+	// for that call in RetryOverrides. By default only errors classified
+	// as transient by package fault are retried.
+	Retry          retry.Policy
+	RetryOverrides map[Call]retry.Policy
+
+	// RateLimits caps the request rate of each call across a whole run.
+	// Calls without a limit aren't rate limited. Whether limited or not, a
+	// call that fails with a Retry-After hint, see fault.RateLimitError,
+	// holds back every caller of that call for as long as the server asked.
+	RateLimits map[Call]ratelimit.Limit
+
+	// Timeouts bounds each attempt at a call. An attempt that runs out
+	// fails with a *TimeoutError, which is retried like any transient
+	// error. RunTimeout bounds a whole run, which then fails with
+	// ErrRunTimeout. Zero means no limit.
+	Timeouts   map[Call]time.Duration
+	RunTimeout time.Duration
+
+	// ParticipantsHedge, if set, sends a second GetMeetingParticipants
+	// call alongside one that is slower than most, and takes whichever
+	// returns first. The hedge needs a rate limit token of its own, and is
+	// only sent if one is free right away; both share the timeout of the
+	// attempt they belong to. It carries over between runs, along with the
+	// latencies it has learned.
+	ParticipantsHedge *hedge.Hedger
+
+	// StoreBreaker, if set, short-circuits CreateMeetingDatum while the
+	// store keeps failing, and BreakerPolicy decides what uploads do in
+	// the meantime. Every attempt counts towards the breaker, including
+	// retries. It carries over between runs.
+	StoreBreaker  *breaker.Breaker
+	BreakerPolicy BreakerPolicy
+
+	// ContinueOnError keeps processing the remaining meetings when one
+	// fails. The watermark stops at the last meeting before the first
+	// failure and Process returns a *RunReport listing every failure.
+	//
+	// Errors are classified with package fault. Meetings that are not
+	// found are skipped without holding the watermark back, whether or not
+	// ContinueOnError is set. A permanent failure is dead-lettered and the
+	// watermark moves past it if Processor.DeadLetters is set, whether or
+	// not ContinueOnError is; otherwise it stops the run even in
+	// continue-on-error mode, since no later run could get past it.
+	ContinueOnError bool
+
+	// DrainGrace is how long ProcessGraceful lets meetings that were
+	// already enriched upload once it is asked to stop. Zero waits for
+	// them however long it takes.
+	DrainGrace time.Duration
+}
+
+// CheckpointStore persists the watermark between runs.
+type CheckpointStore interface {
+	// Load returns the zero time if no checkpoint has been saved
+	Load(ctx context.Context) (time.Time, error)
+	Save(ctx context.Context, t time.Time) error
 }
 
 type Processor struct {
 	Client ClientInterface
 	Store  StoreInterface
 	Cfg    Config
+
+	// Optional
+	Checkpoint  CheckpointStore
+	DeadLetters DeadLetterStore
+	Metrics     *Metrics
+	Tracer      trace.Tracer
+
+	// Logger, if set, receives structured logs about the run and every
+	// meeting. Participant emails are never logged in clear text.
+	Logger *slog.Logger
 }
 
+// Process uploads every meeting listed since the last checkpoint and returns
+// the new watermark. If a meeting fails the run, the error is that meeting's
+// *MeetingFailure; meetings that were cancelled because of it see it as
+// their context's cause and aren't reported. A meeting uploaded just before
+// the run is cancelled may not be committed, in which case the next run
+// uploads it again.
 func (p *Processor) Process(ctx context.Context) (time.Time, error) {
-	g, ctx := errgroup.WithContext(ctx)
+	return p.process(ctx, nil, nil)
+}
 
-	// Create channels to connect the stages
-	meetings := make(chan Meeting)
-	datums := make(chan CreateMeetingDatumArguments)
-	tms := make(chan time.Time)
+// process runs Process, draining once stop is done if it isn't nil, and
+// sending events to emit if it isn't nil.
+func (p *Processor) process(ctx, stop context.Context, emit func(Event)) (_ time.Time, err error) {
+	ctx = withRunState(ctx, &runState{limiters: p.newLimiters(), emit: emit})
+	ctx, span := p.tracer().Start(ctx, "Processor.Process")
+	defer func() {
+		endSpan(ctx, span, err)
+	}()
+
+	from, err := p.loadCheckpoint(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := p.checkWindow(from); err != nil {
+		return from, err
+	}
+
+	// Track last successfully uploaded meeting's start time
+	wm := watermark.New(from)
+	p.Metrics.setWatermark(from)
+
+	// Meetings listed behind a later one, which the watermark may already
+	// be past
+	var late lateMeetings
+
+	p.log(ctx, slog.LevelInfo, "run started", slog.Time("from", from))
+	defer func() {
+		p.logRun(ctx, from, late.hold(wm.Watermark()), err)
+	}()
+
+	report, err := p.run(ctx, stop,
+		func(ctx context.Context, out chan<- datum) error {
+			return p.produce(ctx, from, &late, out)
+		},
+		func(ctx context.Context, d datum) error {
+			if d.drained {
+				// Never uploaded, so the watermark must not pass it
+				return nil
+			}
+
+			if d.fail != nil && !d.skipped && !d.deadLettered {
+				wm.Fail(d.seq)
+				return nil
+			}
+
+			late.remove(d.seq)
+			if t, advanced := wm.Done(d.seq, d.meeting.Start); advanced {
+				t = late.hold(t)
+				if err := p.saveCheckpoint(ctx, t); err != nil {
+					return err
+				}
+				p.Metrics.setWatermark(t)
+				p.event(ctx, WatermarkAdvanced{Watermark: t})
+			}
+			return nil
+		},
+	)
+
+	mark := wm.Watermark()
+	if held := late.hold(mark); held.Before(mark) {
+		// However the run ended, don't resume past a late meeting that
+		// wasn't uploaded
+		mark = held
+		if serr := p.saveCheckpoint(context.WithoutCancel(ctx), mark); err == nil {
+			err = serr
+		}
+		p.Metrics.setWatermark(mark)
+	}
+	if err != nil {
+		return mark, err
+	}
+
+	return mark, report.Err()
+}
+
+// run connects source to the transform and upload stages. source must
+// number the meetings it sends in listing order, starting at 0. commit is
+// called from a single goroutine with every meeting, in listing order, once
+// it has been uploaded, skipped, has failed in continue-on-error mode or
+// was drained.
+//
+// Once stop is done, if it isn't nil, source is cancelled and meetings that
+// haven't been enriched yet are drained: passed on without being enriched
+// or uploaded.
+func (p *Processor) run(
+	ctx, stop context.Context,
+	source func(ctx context.Context, out chan<- datum) error,
+	commit func(ctx context.Context, d datum) error,
+) (*RunReport, error) {
+	if p.Cfg.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, p.Cfg.RunTimeout, ErrRunTimeout)
+		defer cancel()
+	}
+
+	// The first error cancels the run with itself as the cause, so that
+	// meetings cancelled because of it can tell it wasn't their fault, and
+	// it is what the run returns, whichever stage gave up first
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	cause := func(err error) error {
+		if err != nil {
+			cancel(err)
+		}
+		return err
+	}
+	failFast := func(fn func(ctx context.Context, d datum) (datum, error)) func(ctx context.Context, d datum) (datum, error) {
+		return func(ctx context.Context, d datum) (datum, error) {
+			d, err := fn(ctx, d)
+			return d, cause(err)
+		}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	accepting := p.drain(ctx, stop, cancel)
 
 	// Source
-	g.Go(func() error {
-		return p.produce(ctx, meetings)
+	meetings := pipeline.Source(ctx, g, func(ctx context.Context, out chan<- datum) error {
+		err := source(accepting, out)
+		if err != nil && accepting.Err() != nil && ctx.Err() == nil {
+			// Stopped listing to drain
+			return nil
+		}
+		return cause(err)
 	})
 
+	// Releases a meeting dropped on the way to the sink
+	discard := func(d datum) {
+		d.closeContent()
+		d.endSpan(ctx, nil)
+	}
+
+	var reorder *pipeline.Reorder[datum]
+	if p.Cfg.TransformerUnordered || p.Cfg.UploaderUnordered {
+		window := p.Cfg.ReorderWindow
+		if window == 0 {
+			window = p.transformerConcurrency() + p.uploaderConcurrency()
+		}
+		reorder = pipeline.NewReorder(window, func(d datum) int { return d.seq })
+		reorder.Discard = discard
+		reorder.Buffered = func(delta int) { p.Metrics.channelAdd(metricChannelReorder, delta) }
+		meetings = reorder.Admit(ctx, g, meetings)
+	}
+
 	// Stage 2
-	g.Go(func() error {
-		return <-p.transform(ctx, meetings, datums)
+	datums := pipeline.Map(ctx, g, meetings, pipeline.Stage[datum, datum]{
+		Concurrency: p.transformerConcurrency(),
+		Unordered:   p.Cfg.TransformerUnordered,
+		Fn:          failFast(drained(accepting, adapt(p.Cfg.TransformerLimiter, p.Metrics.measure(metricStageTransform, p.transform)))),
+		Discard:     discard,
 	})
 
 	// Stage 3
-	g.Go(func() error {
-		return <-p.upload(ctx, datums, tms)
+	done := pipeline.Map(ctx, g, datums, pipeline.Stage[datum, datum]{
+		Concurrency: p.uploaderConcurrency(),
+		Unordered:   p.Cfg.UploaderUnordered,
+		Fn:          failFast(adapt(p.Cfg.UploaderLimiter, p.Metrics.measure(metricStageUpload, p.upload))),
 	})
 
+	// Back to listing order for the sink
+	if reorder != nil {
+		done = reorder.Restore(ctx, g, done)
+	}
+
 	// Sink
-	// Track last successfully uploaded meeting's start time
-	var t time.Time
-	for tm := range tms {
-		t = tm
+	report := &RunReport{}
+	pipeline.Sink(ctx, g, done, func(ctx context.Context, d datum) error {
+		switch {
+		case d.drained:
+			report.Drained++
+		case d.skipped:
+			report.Skipped = append(report.Skipped, *d.fail)
+		case d.fail != nil:
+			report.Failures = append(report.Failures, *d.fail)
+		default:
+			report.Uploaded++
+		}
+
+		return cause(commit(ctx, d))
+	})
+
+	err := g.Wait()
+	if err != nil {
+		if c := context.Cause(ctx); c != nil {
+			err = c
+		}
 	}
+	return report, err
+}
 
-	return t, g.Wait()
+// runState is what a single run owns. It travels in the run's context
+// rather than on the Processor, so that runs on the same Processor don't
+// share it.
+type runState struct {
+	// For the calls the run makes
+	limiters map[Call]*ratelimit.Limiter
+
+	// Set by Run
+	emit func(Event)
 }
 
-func (p *Processor) produce(ctx context.Context, out chan<- Meeting) error {
-	defer close(out)
+type runStateKey struct{}
 
-	var nextPageToken string
+func withRunState(ctx context.Context, s *runState) context.Context {
+	return context.WithValue(ctx, runStateKey{}, s)
+}
 
-	// Handle pagination
-	for {
-		resp, err := p.Client.ListPaginatedMeetings(ctx, &ListPaginatedMeetingsParams{
-			NextPageToken: &nextPageToken,
-		})
-		if err != nil {
-			return err
+// runStateFrom returns the state of the run ctx belongs to, if any.
+func runStateFrom(ctx context.Context) *runState {
+	if s, ok := ctx.Value(runStateKey{}).(*runState); ok {
+		return s
+	}
+	return &runState{}
+}
+
+// transformerConcurrency and uploaderConcurrency return the number of
+// workers in each stage. An adaptive stage runs enough workers for its
+// ceiling and lets the limiter decide how many of them may be busy.
+func (p *Processor) transformerConcurrency() int {
+	if p.Cfg.TransformerLimiter != nil {
+		return p.Cfg.TransformerLimiter.Max()
+	}
+	return p.Cfg.TransformerConcurrency
+}
+
+func (p *Processor) uploaderConcurrency() int {
+	if p.Cfg.UploaderLimiter != nil {
+		return p.Cfg.UploaderLimiter.Max()
+	}
+	return p.Cfg.UploaderConcurrency
+}
+
+// drained passes meetings on without calling fn once accepting is done.
+func drained(accepting context.Context, fn func(ctx context.Context, d datum) (datum, error)) func(ctx context.Context, d datum) (datum, error) {
+	return func(ctx context.Context, d datum) (datum, error) {
+		if accepting.Err() != nil {
+			d.drained = true
+			d.endSpan(accepting, nil)
+			return d, nil
 		}
+		return fn(ctx, d)
+	}
+}
 
-		for _, meeting := range resp.Meetings {
-			select {
-			case out <- meeting:
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+// adapt holds fn to the limit of l, if any, and reports back how long each
+// meeting took and whether it failed. Meetings that are passed through, see
+// datum.passThrough, don't count.
+func adapt(l *adaptive.Limiter, fn func(ctx context.Context, d datum) (datum, error)) func(ctx context.Context, d datum) (datum, error) {
+	if l == nil {
+		return fn
+	}
+
+	return func(ctx context.Context, d datum) (datum, error) {
+		if d.passThrough() {
+			return fn(ctx, d)
 		}
 
-		if resp.NextPageToken == "" {
-			break
+		if err := l.Acquire(ctx); err != nil {
+			d.closeContent()
+			d.endSpan(ctx, err)
+			return d, err
 		}
 
-		nextPageToken = resp.NextPageToken
+		start := time.Now()
+		d, err := fn(ctx, d)
+		// A run that is shutting down says nothing about the service
+		failed := (err != nil && ctx.Err() == nil) || (d.fail != nil && !d.skipped)
+		l.Release(time.Since(start), failed)
+		return d, err
 	}
+}
 
-	return nil
+// datum carries a meeting through the transform and upload stages. A
+// meeting that was skipped, or failed in continue-on-error mode, travels on
+// with fail set, and one that was drained with drained set, so the sink
+// still sees every meeting in listing order.
+type datum struct {
+	seq     int
+	meeting Meeting
+	args    CreateMeetingDatumArguments
+	fail    *MeetingFailure
+	skipped bool
+	drained bool
+
+	// Failed for good and kept in a dead-letter store, so the watermark
+	// needn't wait for it
+	deadLettered bool
+
+	// Ended once the meeting has been uploaded, has failed or is dropped
+	span trace.Span
 }
 
-func (p *Processor) transform(ctx context.Context, in <-chan Meeting, out chan<- CreateMeetingDatumArguments) <-chan error {
-	errorC := make(chan error)
+// passThrough reports whether d is done with and only travels on to the
+// sink.
+func (d datum) passThrough() bool {
+	return d.fail != nil || d.drained
+}
 
-	go func() {
-		defer close(errorC)
-		defer close(out)
-		s := stream.New().WithMaxGoroutines(p.Cfg.TransformerConcurrency)
-		for m := range in {
-			m := m
-			s.Go(func() stream.Callback {
-				enriched, err := p.enrich(ctx, m)
-				return func() {
-					if err != nil {
-						select {
-						// No-op if we've already sent an error
-						case errorC <- err:
-							// Disable sends
-							out = nil
-						default:
-						}
-					} else {
-						select {
-						case out <- enriched:
-						case <-ctx.Done():
-							return
-						}
-					}
-				}
-			})
+// closeContent closes the downloaded content, if any. The pipeline owns it
+// from the moment DownloadMeeting returns, and every datum is closed exactly
+// once: after it has been uploaded, or when it is dropped.
+func (d *datum) closeContent() {
+	if d.args.Content != nil {
+		d.args.Content.Close()
+		d.args.Content = nil
+	}
+}
+
+// handleFailure decides what to do about a meeting whose calls failed,
+// after any retries of transient errors:
+//   - meetings that no longer exist upstream are skipped and don't hold
+//     the watermark back
+//   - permanent failures are dead-lettered and recorded on d, so that the
+//     watermark moves past them
+//   - other failures hold the watermark back, so the next run lists them
+//     again and they aren't dead-lettered: they are recorded on d in
+//     continue-on-error mode or returned to abort the run
+//
+// It returns nil if the pipeline should carry on.
+func (p *Processor) handleFailure(ctx context.Context, d *datum, err error) error {
+	if ctx.Err() != nil {
+		// Not this meeting's fault. The run reports the cause once, so
+		// don't drown it in every meeting that was cancelled because of it
+		p.log(ctx, slog.LevelDebug, "meeting cancelled",
+			append(meetingLogAttrs(d.meeting), slog.Any("cause", context.Cause(ctx)))...)
+		return err
+	}
+
+	if errors.Is(err, breaker.ErrOpen) {
+		// The store is down, which isn't this meeting's fault either.
+		// It is picked up again by the next run
+		return err
+	}
+
+	if fault.IsNotFound(err) && errors.As(err, &d.fail) {
+		d.skipped = true
+		p.event(ctx, Failed{Meeting: d.meeting, Failure: *d.fail, Skipped: true})
+		return nil
+	}
+
+	var mf *MeetingFailure
+	if errors.As(err, &mf) {
+		p.event(ctx, Failed{Meeting: d.meeting, Failure: *mf})
+	}
+
+	if fault.IsPermanent(err) {
+		// Retrying it on every run would hold the watermark back for
+		// good. Once dead-lettered it is left to Replay and the run
+		// carries on, whatever ContinueOnError says; if it can't be,
+		// the run stops for someone to look at it
+		if p.DeadLetters == nil && !d.deadLettered {
+			return err
 		}
-		s.Wait()
-		select {
-		case errorC <- nil:
-		default:
+		if dlErr := p.deadLetter(ctx, d.meeting, err); dlErr != nil {
+			return errors.Join(err, dlErr)
 		}
-	}()
+		if errors.As(err, &d.fail) {
+			d.deadLettered = true
+			return nil
+		}
+	}
+
+	if p.Cfg.ContinueOnError && errors.As(err, &d.fail) {
+		return nil
+	}
+
+	return err
+}
+
+func (p *Processor) loadCheckpoint(ctx context.Context) (time.Time, error) {
+	if p.Checkpoint == nil {
+		return time.Time{}, nil
+	}
+	return p.Checkpoint.Load(ctx)
+}
 
-	return errorC
+func (p *Processor) saveCheckpoint(ctx context.Context, t time.Time) error {
+	if p.Checkpoint == nil {
+		return nil
+	}
+	return p.Checkpoint.Save(ctx, t)
+}
+
+func (p *Processor) transform(ctx context.Context, d datum) (datum, error) {
+	p.event(ctx, EnrichStarted{Meeting: d.meeting})
+	start := time.Now()
+
+	var err error
+	d.args, err = p.enrich(d.withSpan(ctx), d.meeting)
+	p.event(ctx, EnrichFinished{Meeting: d.meeting, Duration: time.Since(start), Err: err})
+	if err != nil {
+		err = p.handleFailure(ctx, &d, err)
+		d.endSpan(ctx, err)
+	}
+	return d, err
 }
 
 func (p *Processor) enrich(ctx context.Context, m Meeting) (CreateMeetingDatumArguments, error) {
@@ -178,59 +615,62 @@ func (p *Processor) enrich(ctx context.Context, m Meeting) (CreateMeetingDatumAr
 		Start: m.Start,
 	}
 
+	// A failed call cancels its sibling with the failure as the cause
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
 		var err error
-		args.Content, err = p.Client.DownloadMeeting(ctx, m.DownloadURL)
-		return err
+		var attempts int
+		args.Content, attempts, err = p.downloadMeeting(ctx, m)
+		if err != nil {
+			err = failure(m, StageDownload, err, attempts)
+			cancel(err)
+			return err
+		}
+		return nil
 	})
 
 	g.Go(func() error {
 		var err error
-		args.Participants, err = p.Client.GetMeetingParticipants(ctx, m.ID)
-		return err
+		var attempts int
+		args.Participants, attempts, err = p.getMeetingParticipants(ctx, m)
+		if err != nil {
+			err = failure(m, StageParticipants, err, attempts)
+			cancel(err)
+			return err
+		}
+		return nil
 	})
 
-	return args, g.Wait()
-}
-
-func (p *Processor) upload(ctx context.Context, in <-chan CreateMeetingDatumArguments, out chan<- time.Time) <-chan error {
-	errorC := make(chan error)
-
-	go func() {
-		defer close(errorC)
-		defer close(out)
-		s := stream.New().WithMaxGoroutines(p.Cfg.UploaderConcurrency)
-		for args := range in {
-			args := args
-			s.Go(func() stream.Callback {
-				err := p.Store.CreateMeetingDatum(ctx, args)
-				return func() {
-					if err != nil {
-						select {
-						// No-op if we've already sent an error
-						case errorC <- err:
-							// Disable sends
-							out = nil
-						default:
-						}
-					} else {
-						select {
-						case out <- args.Start:
-						case <-ctx.Done():
-							return
-						}
-					}
-				}
-			})
-		}
-		s.Wait()
-		select {
-		case errorC <- nil:
-		default:
+	if err := g.Wait(); err != nil {
+		// The download may have succeeded even though its sibling failed
+		if args.Content != nil {
+			args.Content.Close()
 		}
-	}()
+		return CreateMeetingDatumArguments{}, err
+	}
+
+	return args, nil
+}
+
+func (p *Processor) upload(ctx context.Context, d datum) (datum, error) {
+	// Meetings that already failed are passed through to the sink
+	if d.passThrough() {
+		return d, nil
+	}
+
+	start := time.Now()
+	attempts, err := p.createMeetingDatum(d.withSpan(ctx), d.meeting, d.args)
+	d.closeContent()
+	if err != nil {
+		err = p.handleFailure(ctx, &d, failure(d.meeting, StageUpload, err, attempts))
+		d.endSpan(ctx, err)
+		return d, err
+	}
 
-	return errorC
+	d.endSpan(ctx, nil)
+	p.event(ctx, Uploaded{Meeting: d.meeting, Duration: time.Since(start)})
+	return d, nil
 }
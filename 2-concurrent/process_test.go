@@ -2,22 +2,37 @@ package concurrent_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
-	"os"
+	"net/http/httptest"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"example.com/pipelines-and-cancellation/adaptive"
+	"example.com/pipelines-and-cancellation/breaker"
+	"example.com/pipelines-and-cancellation/checkpoint"
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/hedge"
+	"example.com/pipelines-and-cancellation/internal/fixture"
+	"example.com/pipelines-and-cancellation/ratelimit"
+	"example.com/pipelines-and-cancellation/retry"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestProcess(t *testing.T) {
+	// Every downloaded recording must be closed exactly once
+	var opens, closes atomic.Int64
+
 	const (
 		// 200 milliseconds
 		maxNetworkLatency = 200
@@ -66,7 +81,7 @@ func TestProcess(t *testing.T) {
 				case <-ctx.Done():
 					return nil, ctx.Err()
 				default:
-					return os.Open(url)
+					return fixture.OpenCounted(url, &opens, &closes)
 				}
 			},
 			GetMeetingParticipantsFunc: func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
@@ -107,7 +122,6 @@ func TestProcess(t *testing.T) {
 				// Simulate I/O
 				time.Sleep(time.Duration(rand.Intn(maxNetworkLatency)) * time.Millisecond)
 				io.Copy(io.Discard, args.Content)
-				args.Content.Close()
 				return ctx.Err()
 			},
 		},
@@ -124,8 +138,965 @@ func TestProcess(t *testing.T) {
 	days-- // IDs start at 1
 	assert.Less(t, got, time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days))
 	assert.Equal(t, 2, runtime.NumGoroutine())
+	assert.Equal(t, opens.Load(), closes.Load())
+}
+
+func TestProcessContinueOnError(t *testing.T) {
+	client, store := newMocks(50)
+	client.DownloadMeetingFunc = func(_ context.Context, url string) (io.ReadCloser, error) {
+		time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+		return readSeekCloser{strings.NewReader("content")}, nil
+	}
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		if meetingID == "13" {
+			return nil, fmt.Errorf("forced participants error for %s", meetingID)
+		}
+		return nil, nil
+	}
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		if args.Topic == "Meeting 20" {
+			return errors.New("forced store error")
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+			ContinueOnError:        true,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 48, report.Uploaded)
+		if assert.Len(t, report.Failures, 2) {
+			assert.Equal(t, "13", report.Failures[0].MeetingID)
+			assert.Equal(t, concurrent.StageParticipants, report.Failures[0].Stage)
+			assert.Equal(t, "20", report.Failures[1].MeetingID)
+			assert.Equal(t, concurrent.StageUpload, report.Failures[1].Stage)
+		}
+	}
+	// Last meeting before the first failure
+	assert.Equal(t, time.Date(2023, time.January, 12, 0, 0, 0, 0, time.UTC), got)
+}
+
+func TestProcessRetry(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	count := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[key]++
+		return calls[key]
+	}
+
+	client, store := newMocks(10)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		n := count("participants " + meetingID)
+		if meetingID == "9" || (meetingID == "5" && n < 3) {
+			return nil, fault.Transient(fmt.Errorf("forced participants error for %s", meetingID))
+		}
+		if meetingID == "3" {
+			return nil, fault.NotFound(fmt.Errorf("meeting %s was deleted", meetingID))
+		}
+		return nil, nil
+	}
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		// Content must be rewound before every attempt
+		b, _ := io.ReadAll(args.Content)
+		if string(b) != "content" {
+			return fmt.Errorf("unexpected content %q", b)
+		}
+		if args.Topic == "Meeting 7" && count("store 7") < 2 {
+			return &fault.RateLimitError{RetryAfter: 5 * time.Millisecond}
+		}
+		if args.Topic == "Meeting 8" {
+			count("store 8")
+			return fault.Permanent(errors.New("forced store error"))
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+			ContinueOnError:        true,
+			Retry: retry.Policy{
+				MaxAttempts: 3,
+				BaseDelay:   time.Millisecond,
+				Jitter:      0.5,
+			},
+		},
+		DeadLetters: &concurrent.FileDeadLetterStore{
+			Path: filepath.Join(t.TempDir(), "dead-letters.jsonl"),
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 7, report.Uploaded)
+		if assert.Len(t, report.Failures, 2) {
+			assert.Equal(t, "8", report.Failures[0].MeetingID)
+			assert.Equal(t, 1, report.Failures[0].Attempts)
+			assert.Equal(t, "9", report.Failures[1].MeetingID)
+			assert.Equal(t, 3, report.Failures[1].Attempts)
+		}
+		if assert.Len(t, report.Skipped, 1) {
+			assert.Equal(t, "3", report.Skipped[0].MeetingID)
+		}
+	}
+	// Skipped meetings and dead-lettered permanent failures don't hold the
+	// watermark back, other failures do
+	assert.Equal(t, time.Date(2023, time.January, 8, 0, 0, 0, 0, time.UTC), got)
+	assert.Equal(t, 3, calls["participants 5"])
+	assert.Equal(t, 1, calls["participants 3"])
+	assert.Equal(t, 2, calls["store 7"])
+	assert.Equal(t, 1, calls["store 8"])
+
+	// 9 is listed again by the next run, so only 8 is dead-lettered
+	letters, err := p.DeadLetters.List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, "8", letters[0].Meeting.ID)
+	}
+}
+
+func TestProcessPermanent(t *testing.T) {
+	client, store := newMocks(10)
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		if args.Topic == "Meeting 3" {
+			return fault.Permanent(errors.New("forced store error"))
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 1,
+			UploaderConcurrency:    1,
+			ContinueOnError:        true,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	// Nowhere to put it, so it stops the run even in continue-on-error mode
+	var mf *concurrent.MeetingFailure
+	if assert.ErrorAs(t, gerr, &mf) {
+		assert.Equal(t, "3", mf.MeetingID)
+	}
+	assert.True(t, fault.IsPermanent(gerr))
+	var report *concurrent.RunReport
+	assert.False(t, errors.As(gerr, &report))
+	// Meeting 2 may not have been committed before the run stopped
+	assert.Contains(t, []time.Time{
+		time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC),
+	}, got)
+}
+
+func TestProcessPermanentDeadLettered(t *testing.T) {
+	client, store := newMocks(10)
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		if args.Topic == "Meeting 3" {
+			return fault.Permanent(errors.New("forced store error"))
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 1,
+			UploaderConcurrency:    1,
+		},
+		Checkpoint: &checkpoint.FileStore{Path: filepath.Join(t.TempDir(), "watermark")},
+		DeadLetters: &concurrent.FileDeadLetterStore{
+			Path: filepath.Join(t.TempDir(), "dead-letters.jsonl"),
+		},
+	}
+	ctx := context.Background()
+
+	got, gerr := p.Process(ctx)
+
+	// Dead-lettered, so the watermark moves past it even though the run
+	// doesn't continue on error, and the next run doesn't list it again
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 9, report.Uploaded)
+		if assert.Len(t, report.Failures, 1) {
+			assert.Equal(t, "3", report.Failures[0].MeetingID)
+		}
+	}
+	want := time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, want, got)
+	saved, err := p.Checkpoint.Load(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, want, saved)
+
+	letters, err := p.DeadLetters.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, "3", letters[0].Meeting.ID)
+	}
 }
 
+func TestProcessRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	var stores []time.Time
+	var pausedAt time.Time
+
+	client, store := newMocks(10)
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now()
+		stores = append(stores, now)
+		if args.Topic == "Meeting 2" && pausedAt.IsZero() {
+			pausedAt = now
+			return &fault.RateLimitError{RetryAfter: 30 * time.Millisecond}
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 5,
+			UploaderConcurrency:    5,
+			Retry:                  retry.Policy{MaxAttempts: 2},
+			RateLimits: map[concurrent.Call]ratelimit.Limit{
+				concurrent.CallDownloadMeeting: {Rate: 200, Burst: 1},
+			},
+		},
+	}
+
+	start := time.Now()
+	got, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	assert.Equal(t, time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC), got)
+	// 10 downloads, 5ms apart
+	assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+	// No upload starts while the store asked us to back off
+	assert.Len(t, stores, 11)
+	for _, s := range stores {
+		if s.After(pausedAt) {
+			assert.GreaterOrEqual(t, s.Sub(pausedAt), 25*time.Millisecond)
+		}
+	}
+}
+
+func TestProcessConcurrentRuns(t *testing.T) {
+	client, store := newMocks(10)
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    3,
+			RateLimits: map[concurrent.Call]ratelimit.Limit{
+				concurrent.CallDownloadMeeting: {Rate: 1000, Burst: 2},
+			},
+		},
+	}
+
+	// Every run has its own limiters, so neither run races the other
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, gerr := p.Process(context.Background())
+			assert.NoError(t, gerr)
+			assert.Equal(t, time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC), got)
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, store.CreateMeetingDatumCalls(), 20)
+}
+
+func TestProcessAdaptive(t *testing.T) {
+	// The store slows down once it has more than 3 uploads in flight
+	var inflight, peak atomic.Int64
+
+	uploader := adaptive.New(adaptive.Config{
+		Min:     1,
+		Max:     10,
+		Initial: 10,
+		Latency: 10 * time.Millisecond,
+	})
+
+	client, store := newMocks(100)
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		if n > 3 {
+			time.Sleep(20 * time.Millisecond)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 10,
+			UploaderLimiter:        uploader,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	assert.Equal(t, time.Date(2023, time.January, 100, 0, 0, 0, 0, time.UTC), got)
+	assert.LessOrEqual(t, peak.Load(), int64(10))
+	// Backed off from the ceiling towards what the store can take
+	assert.Less(t, uploader.Limit(), 10)
+}
+
+func TestProcessCancelCause(t *testing.T) {
+	var mu sync.Mutex
+	var causes []error
+
+	client, store := newMocks(20)
+	client.GetMeetingParticipantsFunc = func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
+		if meetingID == "5" {
+			return nil, errors.New("forced participants error")
+		}
+
+		// Every other meeting is still in flight when 5 fails
+		<-ctx.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		causes = append(causes, context.Cause(ctx))
+		return nil, ctx.Err()
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 10,
+			UploaderConcurrency:    1,
+		},
+	}
+
+	_, gerr := p.Process(context.Background())
+
+	// The meeting that caused the failure, not one that was cancelled
+	var mf *concurrent.MeetingFailure
+	if assert.ErrorAs(t, gerr, &mf) {
+		assert.Equal(t, "5", mf.MeetingID)
+		assert.Equal(t, concurrent.StageParticipants, mf.Stage)
+	}
+	assert.NotErrorIs(t, gerr, context.Canceled)
+
+	assert.NotEmpty(t, causes)
+	for _, c := range causes {
+		assert.ErrorAs(t, c, &mf)
+		assert.Equal(t, "5", mf.MeetingID)
+	}
+}
+
+func TestProcessTimeout(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	count := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[key]++
+		return calls[key]
+	}
+
+	client, store := newMocks(10)
+	client.GetMeetingParticipantsFunc = func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
+		// 2 hangs once, 6 every time
+		if n := count("participants " + meetingID); (meetingID == "2" && n == 1) || meetingID == "6" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    3,
+			ContinueOnError:        true,
+			Retry: retry.Policy{
+				MaxAttempts: 2,
+				BaseDelay:   time.Millisecond,
+			},
+			Timeouts: map[concurrent.Call]time.Duration{
+				concurrent.CallGetMeetingParticipants: 20 * time.Millisecond,
+			},
+		},
+	}
+
+	_, gerr := p.Process(context.Background())
+
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 9, report.Uploaded)
+		if assert.Len(t, report.Failures, 1) {
+			f := report.Failures[0]
+			assert.Equal(t, "6", f.MeetingID)
+			assert.Equal(t, 2, f.Attempts)
+
+			var te *concurrent.TimeoutError
+			if assert.ErrorAs(t, f.Err, &te) {
+				assert.Equal(t, concurrent.CallGetMeetingParticipants, te.Call)
+				assert.Equal(t, 20*time.Millisecond, te.Timeout)
+			}
+			assert.True(t, fault.IsTimeout(f.Err))
+			assert.NotErrorIs(t, f.Err, context.Canceled)
+			assert.NotErrorIs(t, f.Err, context.DeadlineExceeded)
+		}
+	}
+	assert.Equal(t, 2, calls["participants 2"])
+
+	// A run that runs out of time is told apart from one that is cancelled
+	p.Cfg.Timeouts = nil
+	p.Cfg.RunTimeout = 20 * time.Millisecond
+	_, gerr = p.Process(context.Background())
+	assert.ErrorIs(t, gerr, concurrent.ErrRunTimeout)
+	assert.True(t, fault.IsTimeout(gerr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, gerr = p.Process(ctx)
+	assert.ErrorIs(t, gerr, context.Canceled)
+	assert.False(t, fault.IsTimeout(gerr))
+}
+
+func TestProcessHedge(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	cancelled := make(chan struct{})
+
+	client, store := newMocks(10)
+	client.GetMeetingParticipantsFunc = func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
+		mu.Lock()
+		calls[meetingID]++
+		n := calls[meetingID]
+		mu.Unlock()
+
+		// The first call for 4 is stuck until the hedge beats it
+		if meetingID == "4" && n == 1 {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+		return []concurrent.Participant{{ID: meetingID}}, nil
+	}
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		if len(args.Participants) != 1 {
+			return fmt.Errorf("unexpected participants %v", args.Participants)
+		}
+		return nil
+	}
+
+	h := hedge.New(hedge.Config{Delay: 20 * time.Millisecond, MaxRatio: 0.5})
+	m := concurrent.NewMetrics()
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    3,
+			ParticipantsHedge:      h,
+		},
+		Metrics: m,
+	}
+
+	got, gerr := p.Process(context.Background())
+	assert.NoError(t, gerr)
+	assert.Equal(t, time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC), got)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow call not cancelled")
+	}
+
+	n, hedges := h.Stats()
+	assert.Equal(t, 10, n)
+	assert.Equal(t, 1, hedges)
+	assert.Equal(t, 2, calls["4"])
+
+	// The hedge is a call of its own
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `pipeline_call_duration_seconds_count{call="participants"} 11`+"\n")
+}
+
+func TestProcessHedgeRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+
+	client, store := newMocks(10)
+	client.GetMeetingParticipantsFunc = func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
+		mu.Lock()
+		calls[meetingID]++
+		mu.Unlock()
+
+		if meetingID == "4" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return nil, nil
+	}
+
+	h := hedge.New(hedge.Config{Delay: 10 * time.Millisecond, MaxRatio: 0.5})
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    3,
+			ParticipantsHedge:      h,
+			// The other workers are always waiting for the next token, so
+			// there is none to spare for a hedge
+			RateLimits: map[concurrent.Call]ratelimit.Limit{
+				concurrent.CallGetMeetingParticipants: {Rate: 50, Burst: 1},
+			},
+		},
+	}
+
+	_, gerr := p.Process(context.Background())
+	assert.NoError(t, gerr)
+
+	_, hedges := h.Stats()
+	assert.Zero(t, hedges)
+	assert.Equal(t, 1, calls["4"])
+}
+
+func TestProcessBreaker(t *testing.T) {
+	errDown := fault.Transient(errors.New("store down"))
+
+	t.Run("abort", func(t *testing.T) {
+		var calls atomic.Int32
+		client, store := newMocks(10)
+		store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			calls.Add(1)
+			return errDown
+		}
+		p := concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg: concurrent.Config{
+				TransformerConcurrency: 3,
+				UploaderConcurrency:    1,
+				ContinueOnError:        true,
+				StoreBreaker:           breaker.New(breaker.Config{Threshold: 3, CoolDown: time.Minute}),
+			},
+		}
+
+		_, gerr := p.Process(context.Background())
+
+		// The meeting that found the breaker open aborts the run, even
+		// in continue-on-error mode
+		var mf *concurrent.MeetingFailure
+		if assert.ErrorAs(t, gerr, &mf) {
+			assert.Equal(t, "4", mf.MeetingID)
+			assert.Equal(t, concurrent.StageUpload, mf.Stage)
+		}
+		assert.ErrorIs(t, gerr, breaker.ErrOpen)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		var calls atomic.Int32
+		client, store := newMocks(10)
+		store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			if calls.Add(1) <= 2 {
+				return errDown
+			}
+			return nil
+		}
+		p := concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg: concurrent.Config{
+				TransformerConcurrency: 3,
+				UploaderConcurrency:    1,
+				Retry:                  retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+				StoreBreaker:           breaker.New(breaker.Config{Threshold: 2, CoolDown: 20 * time.Millisecond}),
+				BreakerPolicy:          concurrent.BreakerPause,
+			},
+		}
+
+		start := time.Now()
+		got, gerr := p.Process(context.Background())
+
+		assert.NoError(t, gerr)
+		assert.Equal(t, time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC), got)
+		// Meeting 1 waited out the cool-down instead of using up its
+		// attempts on an open breaker
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		assert.Equal(t, int32(12), calls.Load())
+		assert.Equal(t, breaker.Closed, p.Cfg.StoreBreaker.State())
+	})
+
+	t.Run("pause without rewinding", func(t *testing.T) {
+		var calls atomic.Int32
+		client, store := newMocks(3)
+		client.DownloadMeetingFunc = func(_ context.Context, url string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("content")), nil
+		}
+		store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			if calls.Add(1) == 1 {
+				return errDown
+			}
+			return nil
+		}
+		p := concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg: concurrent.Config{
+				TransformerConcurrency: 3,
+				UploaderConcurrency:    3,
+				ContinueOnError:        true,
+				Retry:                  retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+				StoreBreaker:           breaker.New(breaker.Config{Threshold: 1, CoolDown: 10 * time.Millisecond}),
+				BreakerPolicy:          concurrent.BreakerPause,
+			},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, gerr := p.Process(ctx)
+
+		// The content can't be sent twice, so the meeting whose call
+		// failed fails and the others go through once the store is back
+		var report *concurrent.RunReport
+		if assert.ErrorAs(t, gerr, &report) {
+			assert.Equal(t, 2, report.Uploaded)
+			if assert.Len(t, report.Failures, 1) {
+				assert.ErrorIs(t, report.Failures[0].Err, errDown)
+			}
+		}
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, breaker.Closed, p.Cfg.StoreBreaker.State())
+	})
+
+	t.Run("pause through a long outage", func(t *testing.T) {
+		// Down for far longer than MaxAttempts cool-downs
+		const outage = 150 * time.Millisecond
+		start := time.Now()
+		client, store := newMocks(10)
+		store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			if time.Since(start) < outage {
+				return errDown
+			}
+			return nil
+		}
+		p := concurrent.Processor{
+			Client: client,
+			Store:  store,
+			Cfg: concurrent.Config{
+				TransformerConcurrency: 3,
+				UploaderConcurrency:    2,
+				Retry:                  retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+				StoreBreaker:           breaker.New(breaker.Config{Threshold: 2, CoolDown: 10 * time.Millisecond}),
+				BreakerPolicy:          concurrent.BreakerPause,
+			},
+		}
+
+		got, gerr := p.Process(context.Background())
+
+		assert.NoError(t, gerr)
+		assert.Equal(t, time.Date(2023, time.January, 10, 0, 0, 0, 0, time.UTC), got)
+		assert.GreaterOrEqual(t, time.Since(start), outage)
+	})
+}
+
+func TestProcessUnordered(t *testing.T) {
+	cp := &checkpointRecorder{}
+
+	client, store := newMocks(100)
+	client.DownloadMeetingFunc = func(_ context.Context, url string) (io.ReadCloser, error) {
+		time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+		return readSeekCloser{strings.NewReader("content")}, nil
+	}
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		switch meetingID {
+		case "5":
+			// Head-of-line blocker
+			time.Sleep(50 * time.Millisecond)
+		case "60":
+			return nil, fmt.Errorf("forced participants error for %s", meetingID)
+		}
+		return nil, nil
+	}
+	store.CreateMeetingDatumFunc = func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+		time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+		return nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 4,
+			UploaderConcurrency:    4,
+			TransformerUnordered:   true,
+			UploaderUnordered:      true,
+			ReorderWindow:          16,
+			ContinueOnError:        true,
+		},
+		Checkpoint: cp,
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	var report *concurrent.RunReport
+	if assert.ErrorAs(t, gerr, &report) {
+		assert.Equal(t, 99, report.Uploaded)
+		assert.Len(t, report.Failures, 1)
+	}
+	assert.Equal(t, time.Date(2023, time.January, 59, 0, 0, 0, 0, time.UTC), got)
+	// Committed in listing order, one meeting at a time
+	if assert.Len(t, cp.saves, 59) {
+		for i, s := range cp.saves {
+			assert.Equal(t, time.Date(2023, time.January, i+1, 0, 0, 0, 0, time.UTC), s)
+		}
+	}
+}
+
+func TestProcessWindow(t *testing.T) {
+	from := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	client, store := newMocks(14)
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+			From:                   from,
+			To:                     to,
+			PageSize:               7,
+		},
+	}
+
+	_, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	calls := client.ListPaginatedMeetingsCalls()
+	if assert.Len(t, calls, 2) {
+		for i, call := range calls {
+			assert.Equal(t, from, *call.Params.From)
+			assert.Equal(t, to, *call.Params.To)
+			assert.Equal(t, 7, *call.Params.PageSize)
+			assert.Equal(t, []string{"", "7"}[i], *call.Params.NextPageToken)
+		}
+	}
+	assert.Len(t, store.CreateMeetingDatumCalls(), 14)
+}
+
+func TestProcessSharded(t *testing.T) {
+	epoch := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	days := func(tm time.Time) int {
+		return int(tm.Sub(epoch) / (24 * time.Hour))
+	}
+
+	cp := &checkpointRecorder{}
+	client, store := newMocks(0)
+	client.ListPaginatedMeetingsFunc = func(_ context.Context, params *concurrent.ListPaginatedMeetingsParams) (concurrent.ListPaginatedMeetingsResponse, error) {
+		time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+
+		// Both ends are inclusive, so neighbouring shards overlap
+		begin, last := days(*params.From), days(*params.To)
+		offset, _ := strconv.Atoi(*params.NextPageToken)
+		begin += offset
+		if begin > last {
+			return concurrent.ListPaginatedMeetingsResponse{}, nil
+		}
+		end := begin + *params.PageSize
+		if end > last+1 {
+			end = last + 1
+		}
+		return concurrent.ListPaginatedMeetingsResponse{
+			NextPageToken: strconv.Itoa(offset + *params.PageSize),
+			Meetings:      generateMeetings(begin, end),
+		}, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+			From:                   epoch,
+			To:                     epoch.AddDate(0, 0, 100),
+			PageSize:               10,
+			ListShards:             4,
+		},
+		Checkpoint: cp,
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	assert.Equal(t, epoch.AddDate(0, 0, 100), got)
+	// Every meeting exactly once, in Start order
+	if assert.Len(t, cp.saves, 101) {
+		for i, s := range cp.saves {
+			assert.Equal(t, epoch.AddDate(0, 0, i), s)
+		}
+	}
+	froms := make(map[time.Time]bool)
+	for _, call := range client.ListPaginatedMeetingsCalls() {
+		froms[*call.Params.From] = true
+	}
+	assert.Len(t, froms, 4)
+}
+
+func TestProcessPrefetch(t *testing.T) {
+	const prefetch = 3
+
+	var listedBeforeFirstDownload atomic.Int64
+	client, store := newMocks(100)
+	client.GetMeetingParticipantsFunc = func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+		if meetingID == "1" {
+			// Give the producer time to run ahead
+			time.Sleep(50 * time.Millisecond)
+			listedBeforeFirstDownload.Store(int64(len(client.ListPaginatedMeetingsCalls())))
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+			PrefetchPages:          prefetch,
+		},
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	assert.Equal(t, time.Date(2023, time.January, 100, 0, 0, 0, 0, time.UTC), got)
+	// The page being drained plus the ones prefetched behind it
+	assert.Equal(t, int64(1+prefetch), listedBeforeFirstDownload.Load())
+	assert.Len(t, store.CreateMeetingDatumCalls(), 100)
+}
+
+func TestProcessCheckpoint(t *testing.T) {
+	cp := &checkpoint.FileStore{Path: filepath.Join(t.TempDir(), "watermark")}
+	from := time.Date(2023, time.January, 11, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, cp.Save(context.Background(), from))
+
+	client, store := newMocks(0)
+	client.ListPaginatedMeetingsFunc = func(_ context.Context, params *concurrent.ListPaginatedMeetingsParams) (concurrent.ListPaginatedMeetingsResponse, error) {
+		return concurrent.ListPaginatedMeetingsResponse{
+			Meetings: generateMeetings(10, 20),
+		}, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    5,
+		},
+		Checkpoint: cp,
+	}
+
+	got, gerr := p.Process(context.Background())
+
+	assert.NoError(t, gerr)
+	want := time.Date(2023, time.January, 20, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, want, got)
+	for _, call := range client.ListPaginatedMeetingsCalls() {
+		if assert.NotNil(t, call.Params.From) {
+			assert.Equal(t, from, *call.Params.From)
+		}
+	}
+	saved, _ := cp.Load(context.Background())
+	assert.True(t, want.Equal(saved))
+}
+
+// newMocks returns a client that lists meetings 1 to n a page at a time,
+// with seekable content and without participants, and a store that accepts
+// everything. Tests override the calls they are about.
+func newMocks(n int) (*concurrent.ClientInterfaceMock, *concurrent.StoreInterfaceMock) {
+	client := &concurrent.ClientInterfaceMock{
+		ListPaginatedMeetingsFunc: func(_ context.Context, params *concurrent.ListPaginatedMeetingsParams) (concurrent.ListPaginatedMeetingsResponse, error) {
+			begin, _ := strconv.Atoi(*params.NextPageToken)
+			size := 10
+			if params.PageSize != nil {
+				size = *params.PageSize
+			}
+			end := min(begin+size, n)
+
+			var resp concurrent.ListPaginatedMeetingsResponse
+			if begin < end {
+				resp.Meetings = generateMeetings(begin, end)
+			}
+			if end < n {
+				resp.NextPageToken = strconv.Itoa(end)
+			}
+			return resp, nil
+		},
+		DownloadMeetingFunc: func(_ context.Context, url string) (io.ReadCloser, error) {
+			return readSeekCloser{strings.NewReader("content")}, nil
+		},
+		GetMeetingParticipantsFunc: func(_ context.Context, meetingID string) ([]concurrent.Participant, error) {
+			return nil, nil
+		},
+	}
+	store := &concurrent.StoreInterfaceMock{
+		CreateMeetingDatumFunc: func(_ context.Context, args concurrent.CreateMeetingDatumArguments) error {
+			return nil
+		},
+	}
+	return client, store
+}
+
+// checkpointRecorder records every watermark saved. Saves happen from a
+// single goroutine.
+type checkpointRecorder struct {
+	saves []time.Time
+}
+
+func (c *checkpointRecorder) Load(context.Context) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (c *checkpointRecorder) Save(_ context.Context, t time.Time) error {
+	c.saves = append(c.saves, t)
+	return nil
+}
+
+type readSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekCloser) Close() error { return nil }
+
 func generateMeetings(begin, end int) []concurrent.Meeting {
 	l := end - begin
 	meetings := make([]concurrent.Meeting, 0, l)
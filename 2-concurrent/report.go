@@ -0,0 +1,25 @@
+package concurrent
+
+import "example.com/pipelines-and-cancellation/report"
+
+// The run report is shared by every processor, see package report.
+type (
+	Stage          = report.Stage
+	MeetingFailure = report.MeetingFailure
+	RunReport      = report.RunReport
+)
+
+const (
+	StageDownload     = report.StageDownload
+	StageParticipants = report.StageParticipants
+	StageUpload       = report.StageUpload
+)
+
+func failure(m Meeting, stage Stage, err error, attempts int) error {
+	return &MeetingFailure{
+		MeetingID: m.ID,
+		Stage:     stage,
+		Err:       err,
+		Attempts:  attempts,
+	}
+}
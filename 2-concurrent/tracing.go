@@ -0,0 +1,64 @@
+package concurrent
+
+import (
+	"context"
+	"time"
+
+	"example.com/pipelines-and-cancellation/trace"
+)
+
+func (p *Processor) tracer() trace.Tracer {
+	if p.Tracer == nil {
+		return trace.Noop
+	}
+	return p.Tracer
+}
+
+func meetingAttributes(m Meeting) []trace.Attribute {
+	return []trace.Attribute{
+		trace.String("meeting.id", m.ID),
+		trace.String("meeting.start", m.Start.Format(time.RFC3339)),
+	}
+}
+
+// endSpan records err on span, and why ctx was cancelled if it was, then
+// ends it.
+func endSpan(ctx context.Context, span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	if ctx.Err() != nil {
+		span.SetAttributes(trace.String("cancel.cause", context.Cause(ctx).Error()))
+	}
+	span.End()
+}
+
+// startSpan starts the span that follows d from listing to upload.
+func (p *Processor) startSpan(ctx context.Context, d *datum) {
+	_, d.span = p.tracer().Start(ctx, "meeting", meetingAttributes(d.meeting)...)
+}
+
+// withSpan returns ctx carrying d's span, so that calls made for d are its
+// children.
+func (d *datum) withSpan(ctx context.Context) context.Context {
+	if d.span == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, d.span)
+}
+
+// endSpan ends d's span, if it hasn't been ended already, with err or the
+// failure recorded on d.
+func (d *datum) endSpan(ctx context.Context, err error) {
+	if d.span == nil {
+		return
+	}
+	if err == nil && d.fail != nil {
+		err = d.fail
+	}
+	if d.skipped {
+		d.span.SetAttributes(trace.String("meeting.skipped", "true"))
+	}
+	endSpan(ctx, d.span, err)
+	d.span = nil
+}
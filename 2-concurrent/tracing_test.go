@@ -0,0 +1,87 @@
+package concurrent_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	concurrent "example.com/pipelines-and-cancellation/2-concurrent"
+	"example.com/pipelines-and-cancellation/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	var rec trace.Recorder
+
+	// 2 fails once every call has started, so that none is cancelled
+	// before it gets a span
+	var started sync.WaitGroup
+	started.Add(6)
+	client, store := newMocks(3)
+	download := client.DownloadMeetingFunc
+	client.DownloadMeetingFunc = func(ctx context.Context, url string) (io.ReadCloser, error) {
+		started.Done()
+		return download(ctx, url)
+	}
+	client.GetMeetingParticipantsFunc = func(ctx context.Context, meetingID string) ([]concurrent.Participant, error) {
+		started.Done()
+		switch meetingID {
+		case "2":
+			started.Wait()
+			return nil, errors.New("forced participants error")
+		case "3":
+			// Still in flight when the run is cancelled
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+	p := concurrent.Processor{
+		Client: client,
+		Store:  store,
+		Cfg: concurrent.Config{
+			TransformerConcurrency: 3,
+			UploaderConcurrency:    1,
+		},
+		Tracer: &rec,
+	}
+
+	_, gerr := p.Process(context.Background())
+	assert.Error(t, gerr)
+
+	spans := rec.Spans()
+	byName := make(map[string][]trace.RecordedSpan)
+	for _, s := range spans {
+		assert.True(t, s.Ended, "span %s not ended", s.Name)
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+
+	if assert.Len(t, byName["Processor.Process"], 1) {
+		assert.Len(t, byName["Processor.Process"][0].Errors, 1)
+	}
+	assert.Len(t, byName["ClientInterface.ListPaginatedMeetings"], 1)
+
+	assert.Len(t, byName["meeting"], 3)
+	for _, s := range byName["meeting"] {
+		assert.Equal(t, "Processor.Process", s.Parent.Name)
+	}
+
+	for _, name := range []string{"ClientInterface.DownloadMeeting", "ClientInterface.GetMeetingParticipants"} {
+		assert.Len(t, byName[name], 3)
+		for _, s := range byName[name] {
+			assert.Equal(t, "meeting", s.Parent.Name)
+			assert.Equal(t, s.Parent.Attributes["meeting.id"], s.Attributes["meeting.id"])
+			assert.Equal(t, 1, s.Attributes["attempts"])
+
+			if name == "ClientInterface.GetMeetingParticipants" && s.Attributes["meeting.id"] == "3" {
+				assert.Equal(t, "meeting 2: participants: forced participants error", s.Attributes["cancel.cause"])
+			}
+		}
+	}
+
+	for _, s := range byName["StoreInterface.CreateMeetingDatum"] {
+		assert.Equal(t, "1", s.Attributes["meeting.id"])
+	}
+}
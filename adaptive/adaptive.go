@@ -0,0 +1,136 @@
+// Package adaptive adjusts a concurrency limit to what a downstream service
+// can take, using additive increase and multiplicative decrease (AIMD).
+package adaptive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Config bounds a Limiter and says what counts as congestion.
+type Config struct {
+	// Min and Max bound the limit. Min below 1 means 1, Max below Min
+	// means Min.
+	Min int
+	Max int
+
+	// Initial is the limit to start at; zero means Min
+	Initial int
+
+	// Latency is the slowest a call may be before it counts as a sign of
+	// congestion, like a failure. Zero only reacts to failures.
+	Latency time.Duration
+
+	// Decrease is the factor the limit is multiplied by on congestion,
+	// between 0 and 1. Zero means 0.5.
+	Decrease float64
+}
+
+// Limiter hands out up to Limit slots at a time. Every call that completes
+// without congestion raises the limit by 1/Limit, so by about one per
+// round of calls; the first sign of congestion cuts it by
+// Config.Decrease right away, and calls that were already in flight then
+// don't cut it again. It is safe for concurrent use.
+type Limiter struct {
+	cfg Config
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+
+	// Calls that were in flight when the limit was last cut and haven't
+	// been released yet
+	stale int
+
+	// Closed and replaced whenever a slot may have become free
+	wake chan struct{}
+}
+
+// New returns a Limiter for cfg.
+func New(cfg Config) *Limiter {
+	if cfg.Min < 1 {
+		cfg.Min = 1
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.Decrease <= 0 || cfg.Decrease >= 1 {
+		cfg.Decrease = 0.5
+	}
+
+	l := &Limiter{
+		cfg:  cfg,
+		wake: make(chan struct{}),
+	}
+	l.limit = l.clamp(float64(cfg.Initial))
+	return l
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Max returns the ceiling of the limit, i.e. the number of workers that
+// is enough to make use of it.
+func (l *Limiter) Max() int {
+	return l.cfg.Max
+}
+
+// Acquire blocks until a slot is free or ctx is done. Every successful
+// Acquire must be followed by a Release.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inflight < int(l.limit) {
+			l.inflight++
+			l.mu.Unlock()
+			return nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees a slot and adjusts the limit to how the call went.
+func (l *Limiter) Release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	stale := l.stale > 0
+	if stale {
+		l.stale--
+	}
+
+	if failed || (l.cfg.Latency > 0 && latency > l.cfg.Latency) {
+		if !stale {
+			l.limit = l.clamp(l.limit * l.cfg.Decrease)
+			l.stale = l.inflight
+		}
+	} else {
+		l.limit = l.clamp(l.limit + 1/l.limit)
+	}
+
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+func (l *Limiter) clamp(limit float64) float64 {
+	if limit < float64(l.cfg.Min) {
+		return float64(l.cfg.Min)
+	}
+	if limit > float64(l.cfg.Max) {
+		return float64(l.cfg.Max)
+	}
+	return limit
+}
@@ -0,0 +1,74 @@
+package adaptive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/adaptive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("bounds", func(t *testing.T) {
+		l := adaptive.New(adaptive.Config{Min: 2, Max: 4, Initial: 10})
+		assert.Equal(t, 4, l.Limit())
+		assert.Equal(t, 4, l.Max())
+
+		l = adaptive.New(adaptive.Config{})
+		assert.Equal(t, 1, l.Limit())
+		assert.Equal(t, 1, l.Max())
+	})
+
+	t.Run("increases while healthy", func(t *testing.T) {
+		l := adaptive.New(adaptive.Config{Min: 1, Max: 8})
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, l.Acquire(context.Background()))
+			l.Release(time.Millisecond, false)
+		}
+		assert.Equal(t, 8, l.Limit())
+	})
+
+	t.Run("decreases once per round", func(t *testing.T) {
+		l := adaptive.New(adaptive.Config{Min: 1, Max: 16, Initial: 16, Latency: 10 * time.Millisecond})
+
+		// A burst of slow calls that were all in flight together: the
+		// first one cuts the limit, the others were already on their way
+		for i := 0; i < 16; i++ {
+			assert.NoError(t, l.Acquire(context.Background()))
+		}
+		l.Release(time.Second, false)
+		assert.Equal(t, 8, l.Limit())
+		for i := 0; i < 15; i++ {
+			l.Release(time.Second, false)
+		}
+		assert.Equal(t, 8, l.Limit())
+
+		// Started after the cut, so it counts
+		assert.NoError(t, l.Acquire(context.Background()))
+		l.Release(time.Millisecond, true)
+		assert.Equal(t, 4, l.Limit())
+	})
+
+	t.Run("decreases on the first failure", func(t *testing.T) {
+		l := adaptive.New(adaptive.Config{Min: 1, Max: 16, Initial: 16})
+
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, l.Acquire(context.Background()))
+		}
+		l.Release(time.Millisecond, true)
+		assert.Equal(t, 8, l.Limit())
+	})
+
+	t.Run("blocks at the limit", func(t *testing.T) {
+		l := adaptive.New(adaptive.Config{Min: 1, Max: 1})
+		assert.NoError(t, l.Acquire(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, l.Acquire(ctx), context.DeadlineExceeded)
+
+		go l.Release(time.Millisecond, false)
+		assert.NoError(t, l.Acquire(context.Background()))
+	})
+}
@@ -0,0 +1,216 @@
+// Package breaker stops calling a service that keeps failing, so that it
+// gets room to recover instead of every caller hammering it until they all
+// give up.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned instead of calling through an open breaker.
+var ErrOpen = errors.New("circuit breaker open")
+
+var errPanicked = errors.New("call panicked")
+
+// State is where a Breaker is in its cycle.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+
+	// Open fails every call with ErrOpen until Config.CoolDown is over.
+	Open
+
+	// HalfOpen lets a single trial call through. Its success closes the
+	// breaker again, its failure opens it for another cool-down.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Config says when a Breaker opens and for how long.
+type Config struct {
+	// Threshold is the number of failures in a row that opens the
+	// breaker. Zero means 5.
+	Threshold int
+
+	// CoolDown is how long the breaker stays open before it lets a trial
+	// call through. Zero means 10 seconds.
+	CoolDown time.Duration
+
+	// IsFailure reports whether err counts towards opening the breaker.
+	// Errors that don't count close it like a success. If nil, every error
+	// counts. Context errors never count either way: the caller gave up,
+	// which says nothing about the service.
+	IsFailure func(err error) bool
+
+	// OnStateChange, if set, is called on every transition, with the
+	// breaker locked.
+	OnStateChange func(from, to State)
+}
+
+// Breaker is a circuit breaker. It is safe for concurrent use.
+type Breaker struct {
+	cfg Config
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+
+	// Closed and replaced on every transition
+	wake chan struct{}
+}
+
+// New returns a closed Breaker for cfg.
+func New(cfg Config) *Breaker {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 5
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 10 * time.Second
+	}
+	return &Breaker{
+		cfg:  cfg,
+		wake: make(chan struct{}),
+	}
+}
+
+// State returns the current state. An open breaker whose cool-down is
+// over stays Open until a call comes along to try it.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Do calls fn if the breaker lets it through, and returns ErrOpen
+// otherwise. The outcome of fn decides where the breaker goes next; a
+// call that panics counts as a failure.
+func (b *Breaker) Do(fn func() error) (err error) {
+	trial, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	// Still set if fn panics, so that a trial call can't leave the
+	// breaker half-open for good
+	err = errPanicked
+	defer func() {
+		b.record(trial, err)
+	}()
+	return fn()
+}
+
+// Wait blocks until a call is likely to be let through: the breaker is
+// closed, or open with its cool-down over. Another caller may still take
+// the trial call first, so Do can return ErrOpen right after Wait.
+func (b *Breaker) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		wake := b.wake
+		state := b.state
+		cooled := time.Until(b.openedAt.Add(b.cfg.CoolDown))
+		b.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		switch {
+		case state == Closed:
+			return nil
+		case state == Open && cooled <= 0:
+			return nil
+		case state == Open:
+			timer = time.NewTimer(cooled)
+			expired = timer.C
+		}
+
+		select {
+		case <-wake:
+		case <-expired:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// allow reports whether a call may go through, and whether it is the trial
+// call of a half-open breaker.
+func (b *Breaker) allow() (trial bool, _ error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		return false, nil
+	case Open:
+		if time.Since(b.openedAt) >= b.cfg.CoolDown {
+			b.transition(HalfOpen)
+			return true, nil
+		}
+	}
+	return false, ErrOpen
+}
+
+func (b *Breaker) record(trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ignored := errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	failed := err != nil && !ignored && (b.cfg.IsFailure == nil || b.cfg.IsFailure(err))
+
+	switch {
+	case trial && ignored:
+		// Let the next caller try instead, without another cool-down
+		b.transition(Open)
+	case trial && failed:
+		b.open()
+	case trial:
+		b.failures = 0
+		b.transition(Closed)
+	case b.state != Closed || ignored:
+		// Says nothing, or was let through before the breaker opened
+	case failed:
+		b.failures++
+		if b.failures >= b.cfg.Threshold {
+			b.open()
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.transition(Open)
+}
+
+func (b *Breaker) transition(to State) {
+	from := b.state
+	b.state = to
+	close(b.wake)
+	b.wake = make(chan struct{})
+
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,125 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/breaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	errDown := errors.New("down")
+	fail := func() error { return errDown }
+	succeed := func() error { return nil }
+
+	t.Run("opens and recovers", func(t *testing.T) {
+		var transitions []string
+		b := breaker.New(breaker.Config{
+			Threshold: 3,
+			CoolDown:  20 * time.Millisecond,
+			OnStateChange: func(from, to breaker.State) {
+				transitions = append(transitions, from.String()+" -> "+to.String())
+			},
+		})
+
+		// A success resets the count
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		assert.NoError(t, b.Do(succeed))
+		assert.Equal(t, breaker.Closed, b.State())
+
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, b.Do(fail), errDown)
+		}
+		assert.Equal(t, breaker.Open, b.State())
+
+		called := false
+		err := b.Do(func() error {
+			called = true
+			return nil
+		})
+		assert.ErrorIs(t, err, breaker.ErrOpen)
+		assert.False(t, called)
+
+		// The trial fails, so it's open for another cool-down
+		time.Sleep(20 * time.Millisecond)
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		assert.ErrorIs(t, b.Do(succeed), breaker.ErrOpen)
+
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, b.Do(succeed))
+		assert.Equal(t, breaker.Closed, b.State())
+
+		assert.Equal(t, []string{
+			"closed -> open",
+			"open -> half-open",
+			"half-open -> open",
+			"open -> half-open",
+			"half-open -> closed",
+		}, transitions)
+	})
+
+	t.Run("single trial", func(t *testing.T) {
+		b := breaker.New(breaker.Config{Threshold: 1, CoolDown: time.Millisecond})
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		time.Sleep(time.Millisecond)
+
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- b.Do(func() error {
+				<-release
+				return nil
+			})
+		}()
+
+		assert.Eventually(t, func() bool { return b.State() == breaker.HalfOpen }, time.Second, time.Millisecond)
+		assert.ErrorIs(t, b.Do(succeed), breaker.ErrOpen)
+
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, breaker.Closed, b.State())
+	})
+
+	t.Run("panicking trial", func(t *testing.T) {
+		b := breaker.New(breaker.Config{Threshold: 1, CoolDown: time.Millisecond})
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		time.Sleep(time.Millisecond)
+
+		assert.Panics(t, func() {
+			b.Do(func() error { panic("boom") })
+		})
+		// Counted as a failure rather than left half-open
+		assert.Equal(t, breaker.Open, b.State())
+	})
+
+	t.Run("ignored errors", func(t *testing.T) {
+		errNotFound := errors.New("not found")
+		b := breaker.New(breaker.Config{
+			Threshold: 1,
+			IsFailure: func(err error) bool { return !errors.Is(err, errNotFound) },
+		})
+
+		assert.ErrorIs(t, b.Do(func() error { return errNotFound }), errNotFound)
+		assert.ErrorIs(t, b.Do(func() error { return context.Canceled }), context.Canceled)
+		assert.Equal(t, breaker.Closed, b.State())
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		b := breaker.New(breaker.Config{Threshold: 1, CoolDown: 20 * time.Millisecond})
+		assert.NoError(t, b.Wait(context.Background()))
+
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		start := time.Now()
+		assert.NoError(t, b.Wait(context.Background()))
+		assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+
+		assert.ErrorIs(t, b.Do(fail), errDown)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+	})
+}
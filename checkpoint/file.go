@@ -0,0 +1,58 @@
+// Package checkpoint persists the watermark returned by a Processor so
+// that the next run can resume listing from it.
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStore keeps the watermark in a single file. Saves are atomic: the
+// new value is written to a temporary file in the same directory and
+// renamed over Path, so a crash never leaves a torn checkpoint behind.
+type FileStore struct {
+	Path string
+}
+
+// Load returns the zero time if no checkpoint has been saved yet.
+func (s *FileStore) Load(_ context.Context) (time.Time, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(b)))
+}
+
+func (s *FileStore) Save(_ context.Context, t time.Time) error {
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// No-op once the rename has succeeded
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(t.UTC().Format(time.RFC3339Nano) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.Path)
+}
@@ -0,0 +1,39 @@
+package checkpoint_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/checkpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s := checkpoint.FileStore{Path: filepath.Join(dir, "watermark")}
+	ctx := context.Background()
+
+	// Missing file means "start from the beginning"
+	got, err := s.Load(ctx)
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+
+	first := time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.Save(ctx, first))
+
+	second := time.Date(2023, time.January, 3, 12, 30, 0, 5, time.UTC)
+	require.NoError(t, s.Save(ctx, second))
+
+	got, err = s.Load(ctx)
+	require.NoError(t, err)
+	assert.True(t, second.Equal(got))
+
+	// Temporary files must not be left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,129 @@
+// Package fault classifies errors returned by client and store
+// implementations, so that the processors can decide whether to retry a
+// call, skip a meeting or give up.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrTransient marks errors that may go away if the call is retried.
+	ErrTransient = errors.New("transient error")
+
+	// ErrRateLimited marks errors caused by exceeding a quota. Rate limited
+	// errors are transient.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrNotFound marks a resource that no longer exists.
+	ErrNotFound = errors.New("not found")
+
+	// ErrPermanent marks errors that will never go away if retried.
+	ErrPermanent = errors.New("permanent error")
+
+	// ErrTimeout marks a call that ran out of its own time budget, as
+	// opposed to one whose caller gave up. Timeouts are transient.
+	ErrTimeout = errors.New("timeout")
+)
+
+// Transient wraps err so that it is classified as transient.
+func Transient(err error) error {
+	return &classified{err: err, class: ErrTransient}
+}
+
+// NotFound wraps err so that it is classified as not found.
+func NotFound(err error) error {
+	return &classified{err: err, class: ErrNotFound}
+}
+
+// Permanent wraps err so that it is classified as permanent.
+func Permanent(err error) error {
+	return &classified{err: err, class: ErrPermanent}
+}
+
+// Timeout wraps err so that it is classified as a timeout.
+func Timeout(err error) error {
+	return &classified{err: err, class: ErrTimeout}
+}
+
+type classified struct {
+	err   error
+	class error
+}
+
+func (c *classified) Error() string {
+	return c.err.Error()
+}
+
+func (c *classified) Unwrap() error {
+	return c.err
+}
+
+func (c *classified) Is(target error) bool {
+	return target == c.class
+}
+
+// RateLimitError reports that a quota was exceeded. RetryAfter is the hint
+// given by the server, zero if there was none.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	msg := "rate limited"
+	if e.RetryAfter > 0 {
+		msg = fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// IsTransient reports whether err may go away if the call is retried.
+func IsTransient(err error) bool {
+	if IsPermanent(err) || IsNotFound(err) {
+		return false
+	}
+	return errors.Is(err, ErrTransient) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout)
+}
+
+// IsRateLimited reports whether err was caused by exceeding a quota.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsTimeout reports whether err is a call running out of its time budget.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// IsNotFound reports whether err is about a resource that no longer exists.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsPermanent reports whether err is explicitly marked as permanent.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// RetryAfter returns the server's hint for when to retry err, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rl *RateLimitError
+	if errors.As(err, &rl) && rl.RetryAfter > 0 {
+		return rl.RetryAfter, true
+	}
+	return 0, false
+}
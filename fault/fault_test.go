@@ -0,0 +1,47 @@
+package fault_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassification(t *testing.T) {
+	base := errors.New("boom")
+
+	transient := fmt.Errorf("download: %w", fault.Transient(base))
+	assert.True(t, fault.IsTransient(transient))
+	assert.False(t, fault.IsPermanent(transient))
+	assert.ErrorIs(t, transient, base)
+	assert.Equal(t, "download: boom", transient.Error())
+
+	rl := fmt.Errorf("participants: %w", &fault.RateLimitError{RetryAfter: time.Second, Err: base})
+	assert.True(t, fault.IsTransient(rl))
+	assert.True(t, fault.IsRateLimited(rl))
+	after, ok := fault.RetryAfter(rl)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, after)
+
+	notFound := fault.NotFound(base)
+	assert.True(t, fault.IsNotFound(notFound))
+	assert.False(t, fault.IsTransient(notFound))
+
+	timeout := fault.Timeout(base)
+	assert.True(t, fault.IsTimeout(timeout))
+	assert.True(t, fault.IsTransient(timeout))
+
+	// Permanent wins over an inner transient classification
+	permanent := fault.Permanent(fault.Transient(base))
+	assert.True(t, fault.IsPermanent(permanent))
+	assert.False(t, fault.IsTransient(permanent))
+
+	// Unclassified errors are neither
+	assert.False(t, fault.IsTransient(base))
+	assert.False(t, fault.IsPermanent(base))
+	_, ok = fault.RetryAfter(base)
+	assert.False(t, ok)
+}
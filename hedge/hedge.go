@@ -0,0 +1,190 @@
+// Package hedge cuts the latency tail of idempotent calls: a call that is
+// slower than most gets a second, identical call sent alongside it, and
+// whichever completes first wins.
+package hedge
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latencies needed before the percentile is trusted over Config.Delay
+const minSamples = 10
+
+// Config says when a call is hedged.
+type Config struct {
+	// Percentile of recent latencies a call may take before it is hedged,
+	// between 0 and 1. Zero means 0.95.
+	Percentile float64
+
+	// Delay is the least a call waits before it is hedged, so that calls
+	// that are all fast aren't hedged for the sake of a few microseconds.
+	// It is the whole wait until enough latencies have been seen; zero
+	// doesn't hedge until then.
+	Delay time.Duration
+
+	// MaxRatio caps the hedges sent as a fraction of calls, so that a
+	// service that slows down across the board isn't sent twice the load.
+	// Zero means 0.1.
+	MaxRatio float64
+
+	// Window is the number of recent latencies the percentile is taken
+	// over. Zero means 100.
+	Window int
+}
+
+// Hedger keeps the latencies of recent calls and the share of them that
+// were hedged. It is safe for concurrent use.
+type Hedger struct {
+	cfg Config
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	calls     int
+	hedges    int
+}
+
+// New returns a Hedger for cfg.
+func New(cfg Config) *Hedger {
+	if cfg.Percentile <= 0 || cfg.Percentile > 1 {
+		cfg.Percentile = 0.95
+	}
+	if cfg.MaxRatio <= 0 {
+		cfg.MaxRatio = 0.1
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 100
+	}
+	return &Hedger{cfg: cfg}
+}
+
+// Stats returns the number of calls made through h and how many of them
+// were hedged.
+func (h *Hedger) Stats() (calls, hedges int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls, h.hedges
+}
+
+// Admit is asked for a token of its own before a hedge is sent, e.g. to
+// keep to a rate limit. It returns false to skip the hedge, or a function
+// to call with the hedge's outcome once it returns.
+type Admit func() (done func(err error), ok bool)
+
+// Do calls fn and, if it hasn't returned by h's delay and the hedge ratio
+// and admit allow, calls it a second time alongside. The first call to
+// succeed wins and the other one's context is cancelled, without waiting
+// for it to return. A call that fails waits for the other one, if any,
+// and the last error is returned if both fail. A nil h just calls fn, and
+// a nil admit admits every hedge.
+func Do[T any](ctx context.Context, h *Hedger, admit Admit, fn func(ctx context.Context) (T, error)) (T, error) {
+	if h == nil {
+		return fn(ctx)
+	}
+
+	// Cancels the loser
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		v   T
+		err error
+	}
+	// Buffered for both calls, so the loser never blocks
+	results := make(chan result, 2)
+	call := func(done func(error)) {
+		v, err := fn(ctx)
+		if done != nil {
+			done(err)
+		}
+		results <- result{v: v, err: err}
+	}
+
+	start := time.Now()
+	delay, ok := h.start()
+	go call(nil)
+	pending := 1
+
+	var hedge <-chan time.Time
+	if ok {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		hedge = t.C
+	}
+
+	for {
+		select {
+		case <-hedge:
+			hedge = nil
+			if done, ok := h.take(admit); ok {
+				go call(done)
+				pending++
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// What the caller waited, hedge included, so that the
+				// percentile doesn't shrink with every hedge that wins
+				h.observe(time.Since(start))
+				return r.v, nil
+			}
+			if pending == 0 {
+				return r.v, r.err
+			}
+		}
+	}
+}
+
+// start counts a call and returns how long it may take before it is
+// hedged, if it may be hedged at all.
+func (h *Hedger) start() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.calls++
+
+	if len(h.latencies) < min(minSamples, h.cfg.Window) {
+		return h.cfg.Delay, h.cfg.Delay > 0
+	}
+
+	sorted := make([]time.Duration, len(h.latencies))
+	copy(sorted, h.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(h.cfg.Percentile*float64(len(sorted)))) - 1
+	return max(sorted[max(i, 0)], h.cfg.Delay), true
+}
+
+// take reports whether one more hedge fits in the ratio and is admitted,
+// and counts it. admit is called with h locked.
+func (h *Hedger) take(admit Admit) (done func(error), ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if float64(h.hedges+1) > h.cfg.MaxRatio*float64(h.calls) {
+		return nil, false
+	}
+	if admit != nil {
+		if done, ok = admit(); !ok {
+			return nil, false
+		}
+	}
+	h.hedges++
+	return done, true
+}
+
+// observe records how long a call that succeeded took.
+func (h *Hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.latencies) < h.cfg.Window {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % h.cfg.Window
+}
@@ -0,0 +1,175 @@
+package hedge_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/hedge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("hedge wins", func(t *testing.T) {
+		h := hedge.New(hedge.Config{Delay: 5 * time.Millisecond, MaxRatio: 1})
+
+		var n atomic.Int32
+		cancelled := make(chan struct{})
+		v, err := hedge.Do(context.Background(), h, nil, func(ctx context.Context) (int, error) {
+			if n.Add(1) == 1 {
+				// Hangs until it loses
+				<-ctx.Done()
+				close(cancelled)
+				return 0, ctx.Err()
+			}
+			return 2, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, v)
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("loser not cancelled")
+		}
+
+		calls, hedges := h.Stats()
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, 1, hedges)
+	})
+
+	t.Run("fast calls aren't hedged", func(t *testing.T) {
+		h := hedge.New(hedge.Config{Delay: 50 * time.Millisecond, MaxRatio: 1})
+
+		var n atomic.Int32
+		for i := 0; i < 20; i++ {
+			_, err := hedge.Do(context.Background(), h, nil, func(ctx context.Context) (int, error) {
+				n.Add(1)
+				return 0, nil
+			})
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int32(20), n.Load())
+
+		_, hedges := h.Stats()
+		assert.Zero(t, hedges)
+	})
+
+	t.Run("percentile", func(t *testing.T) {
+		// Never hedges until it has seen enough latencies
+		h := hedge.New(hedge.Config{Percentile: 0.5, MaxRatio: 1})
+
+		for i := 0; i < 10; i++ {
+			_, err := hedge.Do(context.Background(), h, nil, func(ctx context.Context) (int, error) {
+				time.Sleep(time.Millisecond)
+				return 0, nil
+			})
+			assert.NoError(t, err)
+		}
+		_, hedges := h.Stats()
+		assert.Zero(t, hedges)
+
+		var n atomic.Int32
+		start := time.Now()
+		_, err := hedge.Do(context.Background(), h, nil, func(ctx context.Context) (int, error) {
+			if n.Add(1) == 1 {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			return 0, nil
+		})
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		_, hedges = h.Stats()
+		assert.Equal(t, 1, hedges)
+	})
+
+	t.Run("max ratio", func(t *testing.T) {
+		// Every call is slower than the fastest ones, which were hedged
+		h := hedge.New(hedge.Config{Percentile: 0.01, Delay: time.Millisecond, MaxRatio: 0.25})
+
+		for i := 0; i < 20; i++ {
+			var n atomic.Int32
+			_, err := hedge.Do(context.Background(), h, nil, func(ctx context.Context) (int, error) {
+				if n.Add(1) == 1 {
+					time.Sleep(5 * time.Millisecond)
+				}
+				return 0, nil
+			})
+			assert.NoError(t, err)
+		}
+
+		calls, hedges := h.Stats()
+		assert.Equal(t, 20, calls)
+		assert.Equal(t, 5, hedges)
+	})
+
+	t.Run("admit", func(t *testing.T) {
+		h := hedge.New(hedge.Config{Delay: time.Millisecond, MaxRatio: 1})
+		slow := func(ctx context.Context) (int, error) {
+			time.Sleep(10 * time.Millisecond)
+			return 0, nil
+		}
+
+		// No token to spare, so no hedge
+		var n atomic.Int32
+		_, err := hedge.Do(context.Background(), h, func() (func(error), bool) {
+			return nil, false
+		}, func(ctx context.Context) (int, error) {
+			n.Add(1)
+			return slow(ctx)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), n.Load())
+		_, hedges := h.Stats()
+		assert.Zero(t, hedges)
+
+		// The hedge reports its own outcome
+		var first atomic.Bool
+		hedged := make(chan error, 1)
+		_, err = hedge.Do(context.Background(), h, func() (func(error), bool) {
+			return func(err error) { hedged <- err }, true
+		}, func(ctx context.Context) (int, error) {
+			if first.CompareAndSwap(false, true) {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			return 0, nil
+		})
+		assert.NoError(t, err)
+		select {
+		case err := <-hedged:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("hedge outcome not reported")
+		}
+		_, hedges = h.Stats()
+		assert.Equal(t, 1, hedges)
+	})
+
+	t.Run("both fail", func(t *testing.T) {
+		h := hedge.New(hedge.Config{Delay: time.Millisecond, MaxRatio: 1})
+		first, second := errors.New("first"), errors.New("second")
+
+		var n atomic.Int32
+		_, err := hedge.Do(context.Background(), h, nil, func(ctx context.Context) (int, error) {
+			if n.Add(1) == 1 {
+				time.Sleep(20 * time.Millisecond)
+				return 0, first
+			}
+			return 0, second
+		})
+		assert.ErrorIs(t, err, first)
+		assert.Equal(t, int32(2), n.Load())
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		v, err := hedge.Do(context.Background(), nil, nil, func(ctx context.Context) (int, error) {
+			return 1, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+	})
+}
@@ -0,0 +1,30 @@
+// Package fixture holds helpers shared by the processors' tests.
+package fixture
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// OpenCounted opens path and counts the open in opens, and every Close of
+// the result in closes, so that tests can check that each downloaded
+// recording is closed exactly once.
+func OpenCounted(path string, opens, closes *atomic.Int64) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	opens.Add(1)
+	return countingCloser{ReadCloser: f, closes: closes}, nil
+}
+
+type countingCloser struct {
+	io.ReadCloser
+	closes *atomic.Int64
+}
+
+func (c countingCloser) Close() error {
+	c.closes.Add(1)
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,303 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, without depending on a Prometheus
+// client.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are histogram upper bounds, in seconds, suited to API calls.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry holds metrics and serves them over HTTP. It is safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter registers a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.register(name, help, "counter", labels, nil)}
+}
+
+// Gauge registers a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r.register(name, help, "gauge", labels, nil)}
+}
+
+// Histogram registers a histogram with the given upper bounds, in
+// increasing order, and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{r.register(name, help, "histogram", labels, buckets)}
+}
+
+func (r *Registry) register(name, help, typ string, labels []string, buckets []float64) *family {
+	f := &family{
+		name:     name,
+		help:     help,
+		typ:      typ,
+		labels:   labels,
+		buckets:  buckets,
+		children: make(map[string]*child),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, f)
+	return f
+}
+
+// ServeHTTP writes every metric in the text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes every metric in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]*family(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range metrics {
+		f.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ f *family }
+
+// With returns the counter for the given label values, in the order the
+// label names were registered.
+func (v *CounterVec) With(values ...string) *Counter {
+	return &Counter{v.f.child(values)}
+}
+
+// Counter only goes up.
+type Counter struct{ c *child }
+
+func (c *Counter) Inc()          { c.c.value.add(1) }
+func (c *Counter) Add(v float64) { c.c.value.add(v) }
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct{ f *family }
+
+// With returns the gauge for the given label values, in the order the
+// label names were registered.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return &Gauge{v.f.child(values)}
+}
+
+// Gauge goes up and down.
+type Gauge struct{ c *child }
+
+func (g *Gauge) Set(v float64) { g.c.value.set(v) }
+func (g *Gauge) Add(v float64) { g.c.value.add(v) }
+func (g *Gauge) Inc()          { g.c.value.add(1) }
+func (g *Gauge) Dec()          { g.c.value.add(-1) }
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct{ f *family }
+
+// With returns the histogram for the given label values, in the order the
+// label names were registered.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return &Histogram{v.f.child(values)}
+}
+
+// Histogram counts observations into buckets.
+type Histogram struct{ c *child }
+
+func (h *Histogram) Observe(v float64) {
+	h.c.mu.Lock()
+	defer h.c.mu.Unlock()
+
+	for i, upper := range h.c.family.buckets {
+		if v <= upper {
+			h.c.buckets[i]++
+		}
+	}
+	h.c.count++
+	h.c.value.add(v)
+}
+
+type family struct {
+	name, help, typ string
+	labels          []string
+	buckets         []float64
+
+	mu       sync.Mutex
+	children map[string]*child
+}
+
+type child struct {
+	family *family
+	values []string
+
+	value float
+
+	// Histograms only. Held to observe and to read, so that a scrape sees
+	// every observation in all of the buckets, the sum and the count, or
+	// in none of them.
+	mu      sync.Mutex
+	count   uint64
+	buckets []uint64
+}
+
+func (f *family) child(values []string) *child {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.children[key]
+	if !ok {
+		c = &child{
+			family:  f,
+			values:  append([]string(nil), values...),
+			buckets: make([]uint64, len(f.buckets)),
+		}
+		f.children[key] = c
+	}
+	return c
+}
+
+func (f *family) write(w *countingWriter) {
+	f.mu.Lock()
+	children := make([]*child, 0, len(f.children))
+	for _, c := range f.children {
+		children = append(children, c)
+	}
+	f.mu.Unlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		a, b := children[i].values, children[j].values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escape(f.help, false))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	for _, c := range children {
+		if f.typ != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, c.labels(), format(c.value.get()))
+			continue
+		}
+
+		c.mu.Lock()
+		buckets := append([]uint64(nil), c.buckets...)
+		count, sum := c.count, c.value.get()
+		c.mu.Unlock()
+
+		for i, upper := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, c.labels("le", format(upper)), buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, c.labels("le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, c.labels(), format(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, c.labels(), count)
+	}
+}
+
+// labels formats the child's labels, followed by an extra name/value
+// pair if given.
+func (c *child) labels(extra ...string) string {
+	if len(c.values) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range c.family.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escape(c.values[i], true))
+	}
+	if len(extra) == 2 {
+		if len(c.values) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extra[0], extra[1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// float is a float64 that can be updated atomically.
+type float struct{ bits atomic.Uint64 }
+
+func (f *float) get() float64  { return math.Float64frombits(f.bits.Load()) }
+func (f *float) set(v float64) { f.bits.Store(math.Float64bits(v)) }
+
+func (f *float) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
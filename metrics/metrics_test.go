@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/pipelines-and-cancellation/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	items := r.Counter("items_total", "Items seen.", "stage")
+	items.With("upload").Inc()
+	items.With("upload").Add(2)
+	items.With("download").Inc()
+
+	wm := r.Gauge("watermark_seconds", "Last watermark.")
+	wm.With().Set(1.5)
+
+	latency := r.Histogram("call_seconds", "Call latency.", []float64{0.1, 1}, "call")
+	latency.With(`say "hi"`).Observe(0.05)
+	latency.With(`say "hi"`).Observe(0.5)
+	latency.With(`say "hi"`).Observe(5)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	body, _ := io.ReadAll(rec.Body)
+	assert.Equal(t, `# HELP items_total Items seen.
+# TYPE items_total counter
+items_total{stage="download"} 1
+items_total{stage="upload"} 3
+# HELP watermark_seconds Last watermark.
+# TYPE watermark_seconds gauge
+watermark_seconds 1.5
+# HELP call_seconds Call latency.
+# TYPE call_seconds histogram
+call_seconds_bucket{call="say \"hi\"",le="0.1"} 1
+call_seconds_bucket{call="say \"hi\"",le="1"} 2
+call_seconds_bucket{call="say \"hi\"",le="+Inf"} 3
+call_seconds_sum{call="say \"hi\""} 5.55
+call_seconds_count{call="say \"hi\""} 3
+`, string(body))
+}
+
+func TestHistogramScrape(t *testing.T) {
+	r := metrics.NewRegistry()
+	h := r.Histogram("call_seconds", "Call latency.", []float64{0.1, 1}).With()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; i++ {
+			h.Observe(0.05)
+		}
+	}()
+
+	// Every scrape is consistent, however it interleaves with Observe
+	for scraping := true; scraping; {
+		select {
+		case <-done:
+			scraping = false
+		default:
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		var low, high, inf, count int
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			fmt.Sscanf(line, `call_seconds_bucket{le="0.1"} %d`, &low)
+			fmt.Sscanf(line, `call_seconds_bucket{le="1"} %d`, &high)
+			fmt.Sscanf(line, `call_seconds_bucket{le="+Inf"} %d`, &inf)
+			fmt.Sscanf(line, `call_seconds_count %d`, &count)
+		}
+		if low != high || high != inf || inf != count {
+			t.Fatalf("inconsistent scrape: %d %d %d %d", low, high, inf, count)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package pipeline connects typed stages with channels. Every stage runs in
+// the errgroup it is given, so the first error cancels the whole pipeline.
+//
+// The group must come from errgroup.WithContext with the ctx passed to the
+// functions below, otherwise stages blocked on a send are never released.
+package pipeline
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sourcegraph/conc/stream"
+	"golang.org/x/sync/errgroup"
+)
+
+// Source starts fn in g and returns the channel it produces into. The
+// channel is closed when fn returns.
+func Source[T any](ctx context.Context, g *errgroup.Group, fn func(ctx context.Context, out chan<- T) error) <-chan T {
+	out := make(chan T)
+	g.Go(func() error {
+		defer close(out)
+		return fn(ctx, out)
+	})
+	return out
+}
+
+// Stage maps In to Out with bounded concurrency. Results keep the input
+// order unless Unordered is set.
+type Stage[In, Out any] struct {
+	Concurrency int
+	Fn          func(ctx context.Context, v In) (Out, error)
+
+	// Unordered sends results as soon as they are ready, so one slow item
+	// doesn't hold back the ones behind it. Use a Reorder to restore the
+	// order further down the pipeline.
+	Unordered bool
+
+	// Discard, if set, is called with every result that was produced
+	// successfully but is dropped because the pipeline is shutting down.
+	// Use it to release resources held by Out.
+	Discard func(v Out)
+}
+
+// Map starts s in g, reading from in, and returns the channel the results
+// are sent to. The channel is closed once in is closed and every result has
+// been sent or dropped.
+//
+// The first error returned by s.Fn fails the group; later results are
+// dropped rather than sent, so downstream stages never see anything that
+// was produced after the failure.
+func Map[In, Out any](ctx context.Context, g *errgroup.Group, in <-chan In, s Stage[In, Out]) <-chan Out {
+	out := make(chan Out)
+	g.Go(func() error {
+		if s.Unordered {
+			return <-s.runUnordered(ctx, in, out)
+		}
+		return <-s.run(ctx, in, out)
+	})
+	return out
+}
+
+func (s Stage[In, Out]) run(ctx context.Context, in <-chan In, out chan<- Out) <-chan error {
+	errorC := make(chan error)
+
+	go func() {
+		defer close(errorC)
+		defer close(out)
+		st := stream.New().WithMaxGoroutines(s.Concurrency)
+		for v := range in {
+			v := v
+			st.Go(func() stream.Callback {
+				res, err := s.Fn(ctx, v)
+				return func() {
+					if err != nil {
+						select {
+						// No-op if we've already sent an error
+						case errorC <- err:
+							// Disable sends
+							out = nil
+						default:
+						}
+					} else {
+						select {
+						case out <- res:
+						case <-ctx.Done():
+							s.discard(res)
+						}
+					}
+				}
+			})
+		}
+		st.Wait()
+		select {
+		case errorC <- nil:
+		default:
+		}
+	}()
+
+	return errorC
+}
+
+func (s Stage[In, Out]) runUnordered(ctx context.Context, in <-chan In, out chan<- Out) <-chan error {
+	errorC := make(chan error)
+
+	go func() {
+		defer close(errorC)
+		defer close(out)
+
+		// Closed on the first error to disable sends
+		failed := make(chan struct{})
+		var once sync.Once
+
+		workers := s.Concurrency
+		if workers < 1 {
+			workers = 1
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				defer wg.Done()
+				for v := range in {
+					res, err := s.Fn(ctx, v)
+					if err != nil {
+						once.Do(func() {
+							close(failed)
+							errorC <- err
+						})
+						continue
+					}
+
+					select {
+					case <-failed:
+						s.discard(res)
+						continue
+					default:
+					}
+
+					select {
+					case out <- res:
+					case <-failed:
+						s.discard(res)
+					case <-ctx.Done():
+						s.discard(res)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case errorC <- nil:
+		default:
+		}
+	}()
+
+	return errorC
+}
+
+func (s Stage[In, Out]) discard(v Out) {
+	if s.Discard != nil {
+		s.Discard(v)
+	}
+}
+
+// Sink starts fn in g and calls it with every item from in, in order. If fn
+// returns an error, the group fails and the rest of in is left unread.
+func Sink[T any](ctx context.Context, g *errgroup.Group, in <-chan T, fn func(ctx context.Context, v T) error) {
+	g.Go(func() error {
+		for v := range in {
+			if err := fn(ctx, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
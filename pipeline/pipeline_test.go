@@ -0,0 +1,238 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/pipeline"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+func count(n int) func(ctx context.Context, out chan<- int) error {
+	return func(ctx context.Context, out chan<- int) error {
+		for i := 0; i < n; i++ {
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
+
+func jitter() {
+	time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+}
+
+func TestMap(t *testing.T) {
+	g, ctx := errgroup.WithContext(context.Background())
+
+	ints := pipeline.Source(ctx, g, count(100))
+	doubled := pipeline.Map(ctx, g, ints, pipeline.Stage[int, int]{
+		Concurrency: 8,
+		Fn: func(_ context.Context, v int) (int, error) {
+			jitter()
+			return v * 2, nil
+		},
+	})
+
+	var got []int
+	pipeline.Sink(ctx, g, doubled, func(_ context.Context, v int) error {
+		got = append(got, v)
+		return nil
+	})
+
+	assert.NoError(t, g.Wait())
+	if assert.Len(t, got, 100) {
+		for i, v := range got {
+			assert.Equal(t, i*2, v)
+		}
+	}
+}
+
+func TestMapError(t *testing.T) {
+	const failAt = 40
+	errForced := errors.New("forced")
+
+	var produced, discarded atomic.Int64
+	var last int
+
+	g, ctx := errgroup.WithContext(context.Background())
+
+	ints := pipeline.Source(ctx, g, count(1000))
+	mapped := pipeline.Map(ctx, g, ints, pipeline.Stage[int, int]{
+		Concurrency: 8,
+		Fn: func(_ context.Context, v int) (int, error) {
+			jitter()
+			if v == failAt {
+				return 0, errForced
+			}
+			produced.Add(1)
+			return v, nil
+		},
+		Discard: func(int) {
+			discarded.Add(1)
+		},
+	})
+	pipeline.Sink(ctx, g, mapped, func(_ context.Context, v int) error {
+		last = v
+		return nil
+	})
+
+	assert.ErrorIs(t, g.Wait(), errForced)
+	// Nothing after the failure is delivered
+	assert.Less(t, last, failAt)
+	// Every result is either delivered or discarded
+	assert.Equal(t, produced.Load(), int64(last+1)+discarded.Load())
+	assert.Equal(t, 2, runtime.NumGoroutine())
+}
+
+func TestReorder(t *testing.T) {
+	type item struct {
+		seq int
+	}
+
+	const window = 4
+	var inFlight, maxInFlight, buffered, maxBuffered atomic.Int64
+
+	g, ctx := errgroup.WithContext(context.Background())
+	r := pipeline.NewReorder(window, func(v item) int { return v.seq })
+	r.Buffered = func(delta int) {
+		n := buffered.Add(int64(delta))
+		if n > maxBuffered.Load() {
+			// Only Restore's goroutine calls Buffered
+			maxBuffered.Store(n)
+		}
+	}
+
+	ints := pipeline.Source(ctx, g, count(200))
+	items := pipeline.Map(ctx, g, ints, pipeline.Stage[int, item]{
+		Concurrency: 1,
+		Fn: func(_ context.Context, v int) (item, error) {
+			return item{seq: v}, nil
+		},
+	})
+	admitted := r.Admit(ctx, g, items)
+	shuffled := pipeline.Map(ctx, g, admitted, pipeline.Stage[item, item]{
+		Concurrency: 8,
+		Unordered:   true,
+		Fn: func(_ context.Context, v item) (item, error) {
+			n := inFlight.Add(1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			jitter()
+			return v, nil
+		},
+	})
+	restored := r.Restore(ctx, g, shuffled)
+
+	var got []int
+	pipeline.Sink(ctx, g, restored, func(_ context.Context, v item) error {
+		inFlight.Add(-1)
+		got = append(got, v.seq)
+		return nil
+	})
+
+	assert.NoError(t, g.Wait())
+	if assert.Len(t, got, 200) {
+		for i, v := range got {
+			assert.Equal(t, i, v)
+		}
+	}
+	// The sink may not have counted the item whose slot was just released
+	assert.LessOrEqual(t, maxInFlight.Load(), int64(window+1))
+	assert.LessOrEqual(t, maxBuffered.Load(), int64(window))
+	assert.Zero(t, buffered.Load())
+}
+
+func TestMapUnorderedError(t *testing.T) {
+	errForced := errors.New("forced")
+
+	var produced, delivered, discarded atomic.Int64
+
+	g, ctx := errgroup.WithContext(context.Background())
+
+	ints := pipeline.Source(ctx, g, count(1000))
+	mapped := pipeline.Map(ctx, g, ints, pipeline.Stage[int, int]{
+		Concurrency: 8,
+		Unordered:   true,
+		Fn: func(_ context.Context, v int) (int, error) {
+			jitter()
+			if v == 40 {
+				return 0, errForced
+			}
+			produced.Add(1)
+			return v, nil
+		},
+		Discard: func(int) {
+			discarded.Add(1)
+		},
+	})
+	pipeline.Sink(ctx, g, mapped, func(context.Context, int) error {
+		delivered.Add(1)
+		return nil
+	})
+
+	assert.ErrorIs(t, g.Wait(), errForced)
+	assert.Equal(t, produced.Load(), delivered.Load()+discarded.Load())
+	assert.Equal(t, 2, runtime.NumGoroutine())
+}
+
+func TestReorderDiscard(t *testing.T) {
+	errForced := errors.New("forced")
+
+	var produced, delivered, discarded, buffered, held atomic.Int64
+
+	g, ctx := errgroup.WithContext(context.Background())
+	r := pipeline.NewReorder(8, func(v int) int { return v })
+	r.Discard = func(int) {
+		discarded.Add(1)
+		buffered.Add(1)
+	}
+	r.Buffered = func(delta int) {
+		held.Add(int64(delta))
+	}
+
+	ints := pipeline.Source(ctx, g, count(100))
+	admitted := r.Admit(ctx, g, ints)
+	mapped := pipeline.Map(ctx, g, admitted, pipeline.Stage[int, int]{
+		Concurrency: 4,
+		Unordered:   true,
+		Fn: func(_ context.Context, v int) (int, error) {
+			if v == 0 {
+				// Everything behind it waits in the reorder buffer
+				time.Sleep(20 * time.Millisecond)
+				return 0, errForced
+			}
+			produced.Add(1)
+			return v, nil
+		},
+		Discard: func(int) {
+			discarded.Add(1)
+		},
+	})
+	restored := r.Restore(ctx, g, mapped)
+	pipeline.Sink(ctx, g, restored, func(context.Context, int) error {
+		delivered.Add(1)
+		return nil
+	})
+
+	assert.ErrorIs(t, g.Wait(), errForced)
+	assert.Zero(t, delivered.Load())
+	assert.Positive(t, buffered.Load())
+	assert.Zero(t, held.Load())
+	// Admit may drop items that were never mapped
+	assert.GreaterOrEqual(t, discarded.Load(), produced.Load())
+	assert.Equal(t, 2, runtime.NumGoroutine())
+}
@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Reorder restores the order of items that went through unordered stages.
+// Items are numbered by seq, contiguously from 0, and must enter Admit in
+// that order.
+//
+// At most window items can be between Admit and the output of Restore, so
+// the reorder buffer never holds more than window items. A window smaller
+// than the total concurrency of the stages in between limits throughput.
+type Reorder[T any] struct {
+	seq   func(T) int
+	slots chan struct{}
+
+	// Discard, if set, is called with every item that was admitted but is
+	// dropped because the pipeline is shutting down, including those held
+	// in the reorder buffer. Use it to release resources held by T.
+	Discard func(v T)
+
+	// Buffered, if set, is called with +1 when an item enters the reorder
+	// buffer and -1 when it leaves it, whether it's sent on or dropped.
+	Buffered func(delta int)
+}
+
+func NewReorder[T any](window int, seq func(T) int) *Reorder[T] {
+	if window < 1 {
+		window = 1
+	}
+	return &Reorder[T]{
+		seq:   seq,
+		slots: make(chan struct{}, window),
+	}
+}
+
+// Admit starts forwarding in to the returned channel in g, waiting for room
+// in the window before each item.
+func (r *Reorder[T]) Admit(ctx context.Context, g *errgroup.Group, in <-chan T) <-chan T {
+	out := make(chan T)
+	g.Go(func() error {
+		defer close(out)
+		for v := range in {
+			select {
+			case r.slots <- struct{}{}:
+			case <-ctx.Done():
+				r.discard(v)
+				return ctx.Err()
+			}
+
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				r.discard(v)
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	return out
+}
+
+// Restore starts sending the items from in to the returned channel in g, in
+// sequence order.
+func (r *Reorder[T]) Restore(ctx context.Context, g *errgroup.Group, in <-chan T) <-chan T {
+	out := make(chan T)
+	g.Go(func() error {
+		defer close(out)
+		pending := make(map[int]T, cap(r.slots))
+		// Items still waiting for their turn never reach out: the
+		// pipeline is shutting down, or one in front of them was lost to
+		// a failure
+		defer func() {
+			for _, v := range pending {
+				r.buffered(-1)
+				r.discard(v)
+			}
+		}()
+
+		var next int
+		for v := range in {
+			pending[r.seq(v)] = v
+			r.buffered(1)
+			for {
+				w, ok := pending[next]
+				if !ok {
+					break
+				}
+
+				select {
+				case out <- w:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				delete(pending, next)
+				r.buffered(-1)
+				next++
+				<-r.slots
+			}
+		}
+		return nil
+	})
+	return out
+}
+
+func (r *Reorder[T]) discard(v T) {
+	if r.Discard != nil {
+		r.Discard(v)
+	}
+}
+
+func (r *Reorder[T]) buffered(delta int) {
+	if r.Buffered != nil {
+		r.Buffered(delta)
+	}
+}
@@ -0,0 +1,142 @@
+// Package ratelimit spaces out calls to an API with a token bucket.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket. The zero value doesn't limit anything.
+type Limit struct {
+	// Rate is the number of calls allowed per second, on average. Values
+	// of 0 or less don't limit the rate.
+	Rate float64
+
+	// Burst is the number of calls that may be made at once after a quiet
+	// period; values below 1 mean 1
+	Burst int
+}
+
+// Limiter hands out tokens at a Limit. It is safe for concurrent use. A nil
+// *Limiter never blocks.
+type Limiter struct {
+	limit Limit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	paused time.Time
+}
+
+// New returns a Limiter whose bucket starts full.
+func New(limit Limit) *Limiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &Limiter{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a call may be made or ctx is done. A token that was
+// reserved for a caller that gives up is put back.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	ready := l.reserve(time.Now())
+	l.mu.Unlock()
+
+	for {
+		// A pause may start or grow while we wait, so check it each time
+		l.mu.Lock()
+		until := ready
+		if l.paused.After(until) {
+			until = l.paused
+		}
+		l.mu.Unlock()
+
+		d := time.Until(until)
+		if d <= 0 {
+			return nil
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			l.mu.Lock()
+			l.unreserve()
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// Allow takes a token if one is free right away, without waiting, and
+// reports whether it did. It never allows a call while paused.
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.paused.After(now) {
+		return false
+	}
+	if l.reserve(now).After(now) {
+		l.unreserve()
+		return false
+	}
+	return true
+}
+
+// Pause holds back every caller for d, e.g. because the server answered
+// with a Retry-After hint. Overlapping pauses end with the latest one.
+func (l *Limiter) Pause(d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.paused) {
+		l.paused = until
+	}
+}
+
+// reserve takes a token, going into debt if the bucket is empty, and
+// returns when the caller may go ahead.
+func (l *Limiter) reserve(now time.Time) time.Time {
+	if l.limit.Rate <= 0 {
+		return now
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.Rate
+	if burst := float64(l.limit.Burst); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return now
+	}
+	return now.Add(time.Duration(-l.tokens / l.limit.Rate * float64(time.Second)))
+}
+
+func (l *Limiter) unreserve() {
+	if l.limit.Rate > 0 {
+		l.tokens++
+	}
+}
@@ -0,0 +1,79 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("burst then rate", func(t *testing.T) {
+		l := ratelimit.New(ratelimit.Limit{Rate: 100, Burst: 3})
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, l.Wait(context.Background()))
+		}
+		assert.Less(t, time.Since(start), 10*time.Millisecond)
+
+		// 5 more tokens at 10ms each
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, l.Wait(context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		var nilLimiter *ratelimit.Limiter
+		for _, l := range []*ratelimit.Limiter{nilLimiter, ratelimit.New(ratelimit.Limit{})} {
+			start := time.Now()
+			for i := 0; i < 1000; i++ {
+				assert.NoError(t, l.Wait(context.Background()))
+			}
+			assert.Less(t, time.Since(start), 10*time.Millisecond)
+		}
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		l := ratelimit.New(ratelimit.Limit{})
+		l.Pause(20 * time.Millisecond)
+		l.Pause(time.Millisecond)
+
+		start := time.Now()
+		assert.NoError(t, l.Wait(context.Background()))
+		assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		l := ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2})
+		assert.True(t, l.Allow())
+		assert.NoError(t, l.Wait(context.Background()))
+		assert.False(t, l.Allow())
+
+		l = ratelimit.New(ratelimit.Limit{})
+		assert.True(t, l.Allow())
+		l.Pause(time.Second)
+		assert.False(t, l.Allow())
+
+		var nilLimiter *ratelimit.Limiter
+		assert.True(t, nilLimiter.Allow())
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		l := ratelimit.New(ratelimit.Limit{Rate: 1})
+		assert.NoError(t, l.Wait(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+
+		// The abandoned token was put back, so the next one is about a
+		// second away rather than two
+		ctx, cancel = context.WithTimeout(context.Background(), 1500*time.Millisecond)
+		defer cancel()
+		assert.NoError(t, l.Wait(ctx))
+	})
+}
@@ -0,0 +1,68 @@
+// Package report describes the outcome of a run, the same way for every
+// processor.
+package report
+
+import "fmt"
+
+// Stage names the step of the pipeline a meeting failed in.
+type Stage string
+
+const (
+	StageDownload     Stage = "download"
+	StageParticipants Stage = "participants"
+	StageUpload       Stage = "upload"
+)
+
+// MeetingFailure describes a meeting that could not be processed.
+type MeetingFailure struct {
+	MeetingID string
+	Stage     Stage
+	Err       error
+	Attempts  int
+}
+
+func (f *MeetingFailure) Error() string {
+	return fmt.Sprintf("meeting %s: %s: %v", f.MeetingID, f.Stage, f.Err)
+}
+
+func (f *MeetingFailure) Unwrap() error {
+	return f.Err
+}
+
+// RunReport is returned as the error from Process when Config.ContinueOnError
+// is set and at least one meeting failed. Retrieve it with errors.As.
+//
+// Failures and Skipped are in listing order, whatever order the meetings
+// finished in, so the first failure is the one holding the watermark back.
+type RunReport struct {
+	Uploaded int
+	Failures []MeetingFailure
+
+	// Skipped lists meetings that no longer exist upstream
+	Skipped []MeetingFailure
+
+	// Drained counts meetings that were listed but left for the next run
+	// by a graceful stop
+	Drained int
+}
+
+func (r *RunReport) Error() string {
+	return fmt.Sprintf("%d of %d meetings failed, first: %v",
+		len(r.Failures), len(r.Failures)+len(r.Skipped)+r.Uploaded, &r.Failures[0])
+}
+
+func (r *RunReport) Unwrap() []error {
+	errs := make([]error, 0, len(r.Failures))
+	for i := range r.Failures {
+		errs = append(errs, &r.Failures[i])
+	}
+	return errs
+}
+
+// Err returns r if any meeting failed, nil otherwise.
+func (r *RunReport) Err() error {
+	if len(r.Failures) == 0 {
+		return nil
+	}
+	return r
+}
@@ -0,0 +1,99 @@
+// Package retry re-runs failed calls with exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"example.com/pipelines-and-cancellation/fault"
+)
+
+// Policy describes how a call is retried. The zero value makes a single
+// attempt.
+type Policy struct {
+	// MaxAttempts includes the first call; values below 1 mean 1
+	MaxAttempts int
+
+	// Delay before the n-th retry is BaseDelay * 2^(n-1), capped at MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomised so that concurrent callers don't retry in lockstep
+	Jitter float64
+
+	// Retryable reports whether err is worth another attempt. If nil, only
+	// errors classified as transient by package fault are retried.
+	Retryable func(err error) bool
+
+	// OnRetry, if set, is called before waiting to retry after a failed
+	// attempt, with the attempt number, its error and the delay
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// Do calls fn until it succeeds, returns an error that isn't retryable, the
+// attempts are exhausted or ctx is done. It returns the number of attempts
+// made and the last error.
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) (int, error) {
+	var attempt int
+	for {
+		attempt++
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return attempt, err
+		}
+
+		// Honour the server's hint if it asks us to back off for longer
+		d := p.Backoff(attempt)
+		if after, ok := fault.RetryAfter(err); ok && after > d {
+			d = after
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, d)
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, err
+		}
+	}
+}
+
+// Backoff returns the delay before retry number n, starting at 1.
+func (p Policy) Backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		if d > math.MaxInt64/2 {
+			// Doubling again would overflow
+			d = math.MaxInt64
+			break
+		}
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+
+	return d
+}
+
+func (p Policy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.Retryable == nil {
+		return fault.IsTransient(err)
+	}
+	return p.Retryable(err)
+}
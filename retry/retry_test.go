@@ -0,0 +1,124 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/fault"
+	"example.com/pipelines-and-cancellation/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	errFatal := errors.New("fatal")
+
+	p := retry.Policy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    3 * time.Millisecond,
+		Jitter:      0.5,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errFlaky)
+		},
+	}
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		var calls int
+		n, err := p.Do(context.Background(), func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errFlaky
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("reports retries", func(t *testing.T) {
+		var retries []int
+		rp := p
+		rp.OnRetry = func(attempt int, err error, delay time.Duration) {
+			assert.ErrorIs(t, err, errFlaky)
+			assert.LessOrEqual(t, delay, rp.MaxDelay)
+			retries = append(retries, attempt)
+		}
+		n, _ := rp.Do(context.Background(), func(context.Context) error {
+			return errFlaky
+		})
+		assert.Equal(t, 4, n)
+		assert.Equal(t, []int{1, 2, 3}, retries)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		n, err := p.Do(context.Background(), func(context.Context) error {
+			return errFlaky
+		})
+		assert.ErrorIs(t, err, errFlaky)
+		assert.Equal(t, 4, n)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		n, err := p.Do(context.Background(), func(context.Context) error {
+			return errFatal
+		})
+		assert.ErrorIs(t, err, errFatal)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := p
+		slow.BaseDelay = time.Hour
+		n, err := slow.Do(ctx, func(context.Context) error {
+			cancel()
+			return errFlaky
+		})
+		assert.ErrorIs(t, err, errFlaky)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("transient by default", func(t *testing.T) {
+		p := retry.Policy{MaxAttempts: 3}
+		n, err := p.Do(context.Background(), func(context.Context) error {
+			return fault.Transient(errFlaky)
+		})
+		assert.ErrorIs(t, err, errFlaky)
+		assert.Equal(t, 3, n)
+
+		n, err = p.Do(context.Background(), func(context.Context) error {
+			return errFlaky
+		})
+		assert.ErrorIs(t, err, errFlaky)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		p := retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+		begin := time.Now()
+		n, err := p.Do(context.Background(), func(context.Context) error {
+			return &fault.RateLimitError{RetryAfter: 20 * time.Millisecond}
+		})
+		assert.True(t, fault.IsRateLimited(err))
+		assert.Equal(t, 2, n)
+		assert.GreaterOrEqual(t, time.Since(begin), 20*time.Millisecond)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		p := retry.Policy{BaseDelay: time.Millisecond, MaxDelay: 3 * time.Millisecond}
+		assert.Equal(t, time.Millisecond, p.Backoff(1))
+		assert.Equal(t, 2*time.Millisecond, p.Backoff(2))
+		assert.Equal(t, 3*time.Millisecond, p.Backoff(3))
+		assert.Equal(t, 3*time.Millisecond, p.Backoff(60))
+
+		// Uncapped, it saturates instead of overflowing
+		p.MaxDelay = 0
+		assert.Equal(t, time.Duration(math.MaxInt64), p.Backoff(100))
+		p.Jitter = 0.5
+		assert.Positive(t, p.Backoff(100))
+	})
+}
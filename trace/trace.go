@@ -0,0 +1,159 @@
+// Package trace defines the tracing hooks the processors call. Its shape
+// follows OpenTelemetry's, so an adapter to a real tracer is a thin
+// wrapper. Noop is the default; Recorder keeps spans in memory for tests.
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans. The span is the child of the one in ctx, if any, and
+// is stored in the returned context.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a timed operation. End must be called exactly once.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key/value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute  { return Attribute{key, value} }
+func Int(key string, value int) Attribute { return Attribute{key, value} }
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, so that spans
+// started from it are its children.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or a no-op span.
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
+
+// Noop is a Tracer that records nothing.
+var Noop Tracer = noopTracer{}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+// Recorder is a Tracer that keeps every span in memory. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span started by a Recorder.
+type RecordedSpan struct {
+	rec *Recorder
+
+	Name       string
+	Parent     *RecordedSpan
+	Attributes map[string]any
+	Errors     []error
+	Start      time.Time
+	End        time.Time
+	Ended      bool
+}
+
+func (r *Recorder) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	parent, _ := SpanFromContext(ctx).(*recordedSpan)
+
+	s := &RecordedSpan{
+		rec:        r,
+		Name:       name,
+		Attributes: make(map[string]any),
+		Start:      time.Now(),
+	}
+	if parent != nil {
+		s.Parent = parent.s
+	}
+	for _, a := range attrs {
+		s.Attributes[a.Key] = a.Value
+	}
+
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+
+	span := &recordedSpan{s}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns a copy of every span started so far, in the order they
+// were started. Parents point into the copy.
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make([]RecordedSpan, len(r.spans))
+	index := make(map[*RecordedSpan]int, len(r.spans))
+	for i, s := range r.spans {
+		index[s] = i
+		spans[i] = *s
+		spans[i].Attributes = make(map[string]any, len(s.Attributes))
+		for k, v := range s.Attributes {
+			spans[i].Attributes[k] = v
+		}
+		spans[i].Errors = append([]error(nil), s.Errors...)
+	}
+	for i := range spans {
+		if parent := spans[i].Parent; parent != nil {
+			spans[i].Parent = &spans[index[parent]]
+		}
+	}
+	return spans
+}
+
+// recordedSpan implements Span for a RecordedSpan, under the Recorder's
+// lock.
+type recordedSpan struct {
+	s *RecordedSpan
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	s.s.rec.mu.Lock()
+	defer s.s.rec.mu.Unlock()
+	for _, a := range attrs {
+		s.s.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.s.rec.mu.Lock()
+	defer s.s.rec.mu.Unlock()
+	s.s.Errors = append(s.s.Errors, err)
+}
+
+func (s *recordedSpan) End() {
+	s.s.rec.mu.Lock()
+	defer s.s.rec.mu.Unlock()
+	s.s.End = time.Now()
+	s.s.Ended = true
+}
@@ -0,0 +1,44 @@
+package trace_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example.com/pipelines-and-cancellation/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	var rec trace.Recorder
+
+	ctx, parent := rec.Start(context.Background(), "parent", trace.String("k", "v"))
+	_, child := rec.Start(ctx, "child")
+	child.SetAttributes(trace.Int("n", 1))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+
+	spans := rec.Spans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "parent", spans[0].Name)
+		assert.Nil(t, spans[0].Parent)
+		assert.Equal(t, map[string]any{"k": "v"}, spans[0].Attributes)
+		assert.True(t, spans[0].Ended)
+
+		assert.Equal(t, "child", spans[1].Name)
+		if assert.NotNil(t, spans[1].Parent) {
+			assert.Equal(t, "parent", spans[1].Parent.Name)
+		}
+		assert.Equal(t, map[string]any{"n": 1}, spans[1].Attributes)
+		assert.EqualError(t, spans[1].Errors[0], "boom")
+		assert.True(t, spans[1].Ended)
+	}
+}
+
+func TestNoop(t *testing.T) {
+	ctx, span := trace.Noop.Start(context.Background(), "span")
+	span.End()
+	assert.Equal(t, context.Background(), ctx)
+	assert.NotNil(t, trace.SpanFromContext(ctx))
+}
@@ -0,0 +1,83 @@
+// Package watermark computes a safe resume point from work that completes
+// out of order.
+package watermark
+
+import "time"
+
+// Tracker advances the watermark over the contiguous prefix of successfully
+// completed items. Items are keyed by their listing sequence number,
+// starting at 0, so a slow or failed item holds the watermark back no
+// matter how many later items have already finished. The watermark never
+// moves backwards, even if items weren't listed in start time order.
+//
+// A Tracker is not safe for concurrent use; feed it from a single sink.
+type Tracker struct {
+	mark time.Time
+	next int
+	done map[int]time.Time
+
+	// Sequence number of the first failure, -1 if none
+	failed int
+}
+
+// New returns a Tracker whose watermark starts at from.
+func New(from time.Time) *Tracker {
+	return &Tracker{
+		mark:   from,
+		done:   make(map[int]time.Time),
+		failed: -1,
+	}
+}
+
+// Done records that item seq, which started at start, completed
+// successfully. It reports the watermark and whether it advanced.
+func (t *Tracker) Done(seq int, start time.Time) (time.Time, bool) {
+	if seq < t.next || (t.failed >= 0 && seq > t.failed) {
+		// Already accounted for, or stuck behind a failure
+		return t.mark, false
+	}
+
+	t.done[seq] = start
+
+	advanced := false
+	for {
+		s, ok := t.done[t.next]
+		if !ok {
+			break
+		}
+		delete(t.done, t.next)
+		if s.After(t.mark) {
+			t.mark = s
+			advanced = true
+		}
+		t.next++
+	}
+
+	return t.mark, advanced
+}
+
+// Fail records that item seq will never complete. The watermark cannot
+// advance past it, so later completions are no longer retained.
+func (t *Tracker) Fail(seq int) {
+	if seq < t.next || (t.failed >= 0 && seq > t.failed) {
+		return
+	}
+
+	t.failed = seq
+	for s := range t.done {
+		if s > seq {
+			delete(t.done, s)
+		}
+	}
+}
+
+// Watermark returns the start time of the last item of the contiguous
+// prefix of completed items.
+func (t *Tracker) Watermark() time.Time {
+	return t.mark
+}
+
+// Pending returns the number of completed items waiting on an earlier one.
+func (t *Tracker) Pending() int {
+	return len(t.done)
+}
@@ -0,0 +1,44 @@
+package watermark_test
+
+import (
+	"testing"
+	"time"
+
+	"example.com/pipelines-and-cancellation/watermark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2023, time.January, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tr := watermark.New(time.Time{})
+
+	// Out of order completions wait for the gap to fill
+	_, advanced := tr.Done(2, day(3))
+	assert.False(t, advanced)
+	_, advanced = tr.Done(1, day(2))
+	assert.False(t, advanced)
+	assert.True(t, tr.Watermark().IsZero())
+	assert.Equal(t, 2, tr.Pending())
+
+	got, advanced := tr.Done(0, day(1))
+	assert.True(t, advanced)
+	assert.Equal(t, day(3), got)
+	assert.Equal(t, 0, tr.Pending())
+
+	// A failure pins the watermark even if later items succeed
+	tr.Done(4, day(5))
+	tr.Fail(3)
+	got, advanced = tr.Done(5, day(6))
+	assert.False(t, advanced)
+	assert.Equal(t, day(3), got)
+	assert.Equal(t, 0, tr.Pending())
+
+	// Never backwards
+	tr = watermark.New(day(10))
+	got, advanced = tr.Done(0, day(9))
+	assert.False(t, advanced)
+	assert.Equal(t, day(10), got)
+}